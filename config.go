@@ -6,18 +6,15 @@
 package config
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/BurntSushi/toml"
-	"github.com/hnakamur/jsonpreprocess"
-	"gopkg.in/yaml.v2"
 )
 
 // UnmarshalFunc parses the given configuration and populates it into the given variable.
@@ -25,21 +22,19 @@ type UnmarshalFunc func([]byte, interface{}) error
 
 // UnmarshalFuncMap maps configuration file extensions to the corresponding unmarshal functions.
 var UnmarshalFuncMap = map[string]UnmarshalFunc{
-	".yaml": yaml.Unmarshal,
-	".yml":  yaml.Unmarshal,
-	".json": func(bytes []byte, data interface{}) (err error) {
-		if bytes, err = stripJSONComments(bytes); err != nil {
-			return
-		}
-		if err = json.Unmarshal(bytes, data); err != nil {
-			return
-		}
-		return nil
-	},
+	".yaml":  unmarshalYAML,
+	".yml":   unmarshalYAML,
+	".json":  unmarshalJSON,
+	".json5": unmarshalJSON,
 	".toml": func(bytes []byte, data interface{}) error {
 		_, err := toml.Decode(string(bytes), data)
 		return err
 	},
+	".ini":        unmarshalINI,
+	".properties": unmarshalProperties,
+	".env":        unmarshalDotEnv,
+	".hcl":        unmarshalHCL,
+	".xml":        unmarshalXML,
 }
 
 // FileTypeError describes the name of a file whose format is not supported.
@@ -52,12 +47,16 @@ func (s FileTypeError) Error() string {
 
 // ConfigPathError describes a path which cannot be used to set a configuration value.
 type ConfigPathError struct {
-	Path    string
-	Message string
+	Path     string
+	Message  string
+	Position Position // where the value at the path was defined, if known
 }
 
 // Error returns the error message represented by ConfigPathError
 func (s *ConfigPathError) Error() string {
+	if pos := s.Position.String(); pos != "" {
+		return fmt.Sprintf("%v: %q is not a valid path: %v", pos, s.Path, s.Message)
+	}
 	return fmt.Sprintf("%q is not a valid path: %v", s.Path, s.Message)
 }
 
@@ -70,21 +69,78 @@ func (s *ConfigPathError) Error() string {
 // You can also configure an object with a particular configuration value by calling
 // the Configure() method, which sets the object fields with the corresponding configuration value.
 //
-// Config can be loaded from one or multiple JSON, YAML, or TOML files. Files loaded latter
+// Config can be loaded from one or multiple JSON, YAML, TOML, HCL, XML, INI, properties, or .env files. Files loaded latter
 // will be merged with the earlier ones. You may also directly populate Config with
 // the data in memory.
 type Config struct {
-	data  reflect.Value
-	types map[string]reflect.Value
+	data         reflect.Value
+	types        map[string]reflect.Value
+	scopes       map[string]Scope
+	instances    map[reflect.Type]reflect.Value
+	singletons   map[string]reflect.Value
+	secrets      map[string]SecretResolver
+	hooks        []DecodeHook
+	secretPaths  map[string]bool
+	secretFields map[string]secretField
+	typeSecrets  map[string][]secretField
+	sources      map[string]string
+	positions    map[string]Position
+	used         map[string]bool
+	key          []byte
+	ignoreCase   bool
+	typeKey      string
+	mu           sync.Mutex // guards singletons, secretPaths, secretFields, and used, which are updated by Configure()
 }
 
-// New creates a new Config object.
-func New() *Config {
-	return &Config{
-		types: make(map[string]reflect.Value),
+// Option configures a Config object when it is created by New().
+type Option func(*Config)
+
+// WithCaseInsensitiveKeys makes the configuration match map keys and struct field names case-insensitively.
+//
+// With this option, Get() and Set() locate map keys regardless of their cases, keys differing only
+// in case are merged together when loading or setting configuration data, and Configure() matches
+// map keys with struct field names regardless of their cases. When a key is matched case-insensitively,
+// the spelling of the existing key is kept in the configuration data.
+func WithCaseInsensitiveKeys() Option {
+	return func(c *Config) {
+		c.ignoreCase = true
 	}
 }
 
+// WithTypeKey specifies the key of the element in a configuration map that names the type registered by Register()
+// for configuring an interface, e.g. "kind". The key is "type" by default. It can be overridden for a struct field
+// and the values in it by the "typekey" option in the "config" tag of the field, e.g. `config:",typekey=kind"`.
+func WithTypeKey(key string) Option {
+	return func(c *Config) {
+		c.typeKey = key
+	}
+}
+
+// New creates a new Config object with the given options.
+func New(options ...Option) *Config {
+	c := &Config{
+		typeKey:      "type",
+		types:        make(map[string]reflect.Value),
+		scopes:       make(map[string]Scope),
+		instances:    make(map[reflect.Type]reflect.Value),
+		singletons:   make(map[string]reflect.Value),
+		secretPaths:  make(map[string]bool),
+		secretFields: make(map[string]secretField),
+		typeSecrets:  make(map[string][]secretField),
+		sources:      make(map[string]string),
+		positions:    make(map[string]Position),
+		used:         make(map[string]bool),
+		secrets: map[string]SecretResolver{
+			"file": FileSecretResolver,
+			"env":  EnvSecretResolver,
+		},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
 // Get retrieves the configuration value corresponding to the specified path.
 //
 // The path uses a dotted format. A path "Path.To.Xyz" corresponds to the configuration
@@ -95,12 +151,20 @@ func New() *Config {
 // to the value config["Path"][2]["Xyz"], if config["Path"] is an array and config["Path"][2]
 // is a valid.
 //
+// To access a key containing dots, escape the dots with backslashes, such as `Hosts.example\.com.Port`,
+// or enclose the key in brackets and quotes, such as `Hosts["example.com"].Port`. Array indexes may also
+// be enclosed in brackets, such as "Path[2].Xyz". You may use PathOf() to build a path from keys.
+//
 // If any part of the path corresponds to an invalid value (not a map/array, or is nil),
 // the default value will be returned. If you do not specify a default value, nil will be returned.
 //
 // Note that if you specify a default value, the return value of this method will
 // be automatically converted to the same type of the default value.
 // If the conversion cannot be conducted, the default value will be returned.
+//
+// Secret references in the returned value, such as "secret://env/DB_PASS", are resolved
+// by the resolvers registered via RegisterSecretResolver(). If a secret cannot be resolved,
+// the default value will be returned.
 func (c *Config) Get(path string, defaultValue ...interface{}) interface{} {
 	// find the actual default value
 	var d interface{}
@@ -110,16 +174,12 @@ func (c *Config) Get(path string, defaultValue ...interface{}) interface{} {
 
 	// find the config value corresponding to the path
 	// if any part of path cannot be located, return the default value
-	data := c.data
-	parts := strings.Split(path, ".")
-	n := len(parts)
-	for i := 0; i < n-1; i++ {
-		if data = getElement(data, parts[i]); !data.IsValid() {
-			return d
-		}
+	v, err := c.value(path)
+	if err != nil || !v.IsValid() {
+		return d
 	}
-	v := getElement(data, parts[n-1])
-	if !v.IsValid() {
+	// resolve the secret references, if any
+	if v, err = c.resolveSecrets(v, path); err != nil {
 		return d
 	}
 
@@ -134,6 +194,27 @@ func (c *Config) Get(path string, defaultValue ...interface{}) interface{} {
 	return v.Interface()
 }
 
+// value returns the configuration value corresponding to the specified path.
+// An invalid value will be returned if the path cannot be located.
+func (c *Config) value(path string) (reflect.Value, error) {
+	parts, err := parsePath(path)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return c.lookup(c.data, parts), nil
+}
+
+// lookup returns the value located by the keys in the given data.
+// An invalid value will be returned if the keys cannot be located.
+func (c *Config) lookup(data reflect.Value, keys []string) reflect.Value {
+	for _, key := range keys {
+		if data = c.element(data, key); !data.IsValid() {
+			break
+		}
+	}
+	return data
+}
+
 // GetString retrieves the string-typed configuration value corresponding to the specified path.
 // Please refer to Get for the detailed usage explanation.
 func (c *Config) GetString(path string, defaultValue ...string) string {
@@ -191,7 +272,10 @@ func (c *Config) GetBool(path string, defaultValue ...bool) bool {
 // If a value already exists at the specified path, it will be overwritten with the new value.
 // If a partial path has no corresponding configuration value, one will be created. For example,
 // if the map config["Path"] has no "To" element, a new map config["Path"]["To"] will be created
-// so that we can set the value of config["Path"]["To"]["Xyz"].
+// so that we can set the value of config["Path"]["To"]["Xyz"]. Keys containing dots may be escaped
+// or quoted in the same way as described in Get().
+//
+// The value is deep-copied before being set, so later changes to it will not affect the configuration.
 //
 // The method will return an error if it is unable to set the value for various reasons, such as
 // the new value cannot be added to the existing array or map.
@@ -199,20 +283,38 @@ func (c *Config) Set(path string, value interface{}) error {
 	if !c.data.IsValid() {
 		c.data = reflect.ValueOf(make(map[string]interface{}))
 	}
+	if value != nil {
+		value = copyValue(reflect.ValueOf(value)).Interface()
+	}
+
+	parts, err := parsePath(path)
+	if err != nil {
+		return c.pathError(path, err.Error())
+	}
 
 	data := c.data
-	parts := strings.Split(path, ".")
 	n := len(parts)
 	for i := 0; i < n; i++ {
 		switch data.Kind() {
 		case reflect.Map, reflect.Slice, reflect.Array:
 		default:
-			return &ConfigPathError{strings.Join(parts[:i+1], "."), fmt.Sprintf("got %v instead of a map, array, or slice", data.Kind())}
+			// report the position of the value that is not a container
+			return &ConfigPathError{PathOf(parts[:i+1]...), fmt.Sprintf("got %v instead of a map, array, or slice", data.Kind()), c.Position(PathOf(parts[:i]...))}
+		}
+		if c.ignoreCase && data.Kind() == reflect.Map && !hasElement(data, parts[i]) {
+			if key := findKey(data, parts[i]); key.IsValid() {
+				parts[i] = keyString(key)
+			}
 		}
 
 		if i == n-1 {
 			if err := setElement(data, parts[i], value); err != nil {
-				return &ConfigPathError{path, err.Error()}
+				return c.pathError(path, err.Error())
+			}
+			if value == nil {
+				c.removeSources(PathOf(parts...))
+			} else {
+				c.recordSources(PathOf(parts...), reflect.ValueOf(value), "Set")
 			}
 			return nil
 		}
@@ -225,7 +327,7 @@ func (c *Config) Set(path string, value interface{}) error {
 
 		newMap := make(map[string]interface{})
 		if err := setElement(data, parts[i], newMap); err != nil {
-			return &ConfigPathError{strings.Join(parts[:i+1], "."), err.Error()}
+			return c.pathError(PathOf(parts[:i+1]...), err.Error())
 		}
 
 		data = reflect.ValueOf(newMap)
@@ -236,6 +338,7 @@ func (c *Config) Set(path string, value interface{}) error {
 
 // Data returns the complete configuration data.
 // Nil will be returned if the configuration has never been loaded before.
+// Secret references in the data are not resolved.
 func (c *Config) Data() interface{} {
 	if c.data.IsValid() {
 		return c.data.Interface()
@@ -250,32 +353,89 @@ func (c *Config) Data() interface{} {
 // A). If either C1 or C2 is not a map, replace C1 with C2;
 // B). Otherwise, add all key-value pairs of C2 to C1; If a key of C2 is also found in C1,
 // merge the corresponding values in C1 and C2 recursively.
+// C). If a value in C2 is a deletion marker, remove the corresponding key from C1.
+//
+// A deletion marker is a map with a single "$delete" key whose value is true, such as
+// {"$delete": true} in JSON. In YAML, a value may also be tagged with "!delete", such as "key: !delete".
+//
+// The given data are deep-copied before being merged, so they will not be modified by this method
+// or by any subsequent changes to the configuration.
 //
 // Note that this method will clear any existing configuration data.
 func (c *Config) SetData(data ...interface{}) {
 	c.data = reflect.Value{}
+	c.sources = make(map[string]string)
+	c.positions = make(map[string]Position)
 	for _, d := range data {
-		c.data = merge(c.data, reflect.ValueOf(d))
+		v := copyValue(reflect.ValueOf(d))
+		c.recordSources("", v, "SetData")
+		c.data = merge(c.data, v, c.ignoreCase)
 	}
 }
 
+// Clone returns a deep copy of the configuration, including the types registered via Register(), the decode hooks,
+// and the sources and positions of the configuration values.
+// Changes made to the returned configuration will not affect the original one, and vice versa.
+// The instances created by the providers registered with the Singleton scope are not copied,
+// so the returned configuration creates its own singletons.
+func (c *Config) Clone() *Config {
+	clone := New()
+	clone.ignoreCase = c.ignoreCase
+	clone.typeKey = c.typeKey
+	clone.key = c.key
+	clone.data = copyValue(c.data)
+	for name, provider := range c.types {
+		clone.types[name] = provider
+		clone.scopes[name] = c.scopes[name]
+		clone.typeSecrets[name] = c.typeSecrets[name]
+	}
+	for t, instance := range c.instances {
+		clone.instances[t] = instance
+	}
+	for scheme, resolver := range c.secrets {
+		clone.secrets[scheme] = resolver
+	}
+	clone.hooks = append(clone.hooks, c.hooks...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path := range c.secretPaths {
+		clone.secretPaths[path] = true
+	}
+	for id, field := range c.secretFields {
+		clone.secretFields[id] = field
+	}
+	for path, source := range c.sources {
+		clone.sources[path] = source
+	}
+	for path, pos := range c.positions {
+		clone.positions[path] = pos
+	}
+	for path := range c.used {
+		clone.used[path] = true
+	}
+	return clone
+}
+
 // Load loads configuration data from one or multiple files.
 //
 // If multiple configuration files are given, the corresponding configuration data will be merged
 // sequentially according to the rules described in SetData().
 //
-// Supported configuration file formats include JSON, YAML, and TOML. The file formats
-// are determined by the file name extensions (.json, .yaml, .yml, .toml).
-// The method will return any file reading or parsing errors.
+// Supported configuration file formats include JSON, YAML, TOML, HCL, XML, INI, Java properties, and .env. The file formats
+// are determined by the file name extensions (.json, .json5, .yaml, .yml, .toml, .hcl, .xml, .ini, .properties, .env).
+// The method will return any file reading or parsing errors. Parsing errors are returned as ParseError,
+// which contains the file name and the line and column numbers where the problem is found.
+// The positions of the loaded values are recorded and can be obtained by calling Position().
+//
+// Encrypted values in the files are decrypted using the key specified by WithEncryptionKey().
+// An error will be returned if they cannot be decrypted.
 //
 // Note that this method will NOT clear the existing configuration data.
 func (c *Config) Load(files ...string) error {
 	for _, file := range files {
-		var data interface{}
-		if err := load(file, &data); err != nil {
+		if err := c.loadFile(file, parserOf(UnmarshalFuncMap[strings.ToLower(filepath.Ext(file))])); err != nil {
 			return err
 		}
-		c.data = merge(c.data, reflect.ValueOf(data))
 	}
 	return nil
 }
@@ -290,20 +450,49 @@ func (c *Config) Load(files ...string) error {
 // Note that this method will NOT clear the existing configuration data.
 func (c *Config) LoadJSON(data ...[]byte) error {
 	for _, bytes := range data {
-		var err error
-		if bytes, err = stripJSONComments(bytes); err != nil {
+		docs, positions, err := parse("", bytes, singleDocument(parseJSON))
+		if err != nil {
 			return err
 		}
-		var d interface{}
-		if err = json.Unmarshal(bytes, &d); err != nil {
+		if err := c.mergeData(docs[0], "LoadJSON", positions[0]); err != nil {
 			return err
 		}
-		c.data = merge(c.data, reflect.ValueOf(d))
 	}
 	return nil
 }
 
-// load reads and parses a JSON, YAML, or TOML file.
+// loadFile loads configuration data from a file using the given parser.
+// The documents in a multi-document YAML file are merged in order.
+func (c *Config) loadFile(file string, parser documentParser) error {
+	docs, positions, err := readFile(file, parser)
+	if err != nil {
+		return err
+	}
+	for i, doc := range docs {
+		if err := c.mergeData(doc, file, positions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeData decrypts the loaded configuration data and merges them into the configuration.
+// The source and the positions are recorded for the values in the data.
+func (c *Config) mergeData(data interface{}, source string, positions map[string]Position) error {
+	v, err := c.decryptValues(reflect.ValueOf(data), "")
+	if err != nil {
+		if e, ok := err.(*ConfigValueError); ok {
+			e.Position = positions[e.Path]
+		}
+		return err
+	}
+	c.recordSources("", v, source)
+	c.setPositions("", v, positions)
+	c.data = merge(c.data, v, c.ignoreCase)
+	return nil
+}
+
+// load reads and parses a configuration file.
 func load(file string, data interface{}) error {
 	bytes, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -312,21 +501,60 @@ func load(file string, data interface{}) error {
 
 	ext := strings.ToLower(filepath.Ext(file))
 	if unmarshal, ok := UnmarshalFuncMap[ext]; ok {
-		return unmarshal(bytes, data)
+		if err := unmarshal(bytes, data); err != nil {
+			return parseError(file, err)
+		}
+		return nil
 	}
 	return FileTypeError(file)
 }
 
-func merge(v1, v2 reflect.Value) reflect.Value {
+// readFile reads and parses a configuration file using the given parser.
+// It returns the documents in the file and the positions of the values in each document.
+func readFile(file string, parser documentParser) ([]interface{}, []map[string]Position, error) {
+	bytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parser == nil {
+		return nil, nil, FileTypeError(file)
+	}
+	return parse(file, bytes, parser)
+}
+
+// deleteKey is the key of a deletion marker.
+const deleteKey = "$delete"
+
+// merge merges v2 into v1 and returns the result. If ignoreCase is true, map keys differing only
+// in case are merged together, keeping the keys in v1.
+func merge(v1, v2 reflect.Value, ignoreCase bool) reflect.Value {
+	if isDeleteMarker(v2) {
+		return reflect.Value{}
+	}
 	if v1.Kind() != reflect.Map || v2.Kind() != reflect.Map || !v1.IsValid() {
-		return v2
+		return stripDeleteMarkers(v2)
 	}
 
-	for _, key := range v2.MapKeys() {
+	for _, k := range v2.MapKeys() {
+		e2 := mapIndex(v2, k)
+		key := mapKey(v1, k)
+		if !key.IsValid() {
+			continue
+		}
+		if ignoreCase && !v1.MapIndex(key).IsValid() {
+			if k := findKey(v1, keyString(key)); k.IsValid() {
+				key = k
+			}
+		}
 		e1 := mapIndex(v1, key)
-		e2 := mapIndex(v2, key)
+		if isDeleteMarker(e2) {
+			v1.SetMapIndex(key, reflect.Value{})
+			continue
+		}
 		if e1.Kind() == reflect.Map && e2.Kind() == reflect.Map {
-			e2 = merge(e1, e2)
+			e2 = merge(e1, e2, ignoreCase)
+		} else {
+			e2 = stripDeleteMarkers(e2)
 		}
 		v1.SetMapIndex(key, e2)
 	}
@@ -334,6 +562,104 @@ func merge(v1, v2 reflect.Value) reflect.Value {
 	return v1
 }
 
+// mapKey converts a key so that it can be used to index the given map. For example, a key of
+// a map[interface{}]interface{} loaded from YAML can be used to index a map[string]interface{}.
+// An invalid value is returned if the key cannot be converted.
+func mapKey(m, key reflect.Value) reflect.Value {
+	if key.Kind() == reflect.Interface {
+		key = key.Elem()
+	}
+	if !key.IsValid() {
+		return key
+	}
+	t := m.Type().Key()
+	if key.Type().AssignableTo(t) {
+		return key
+	}
+	if t.Kind() == reflect.String {
+		return reflect.ValueOf(keyString(key)).Convert(t)
+	}
+	return reflect.Value{}
+}
+
+// isDeleteMarker checks if a value is a deletion marker, i.e., a map with a single "$delete" key whose value is true.
+func isDeleteMarker(v reflect.Value) bool {
+	if v.Kind() != reflect.Map || v.Len() != 1 {
+		return false
+	}
+	switch v.Type().Key().Kind() {
+	case reflect.String, reflect.Interface:
+	default:
+		return false
+	}
+	e := mapIndex(v, reflect.ValueOf(deleteKey))
+	return e.Kind() == reflect.Bool && e.Bool()
+}
+
+// stripDeleteMarkers removes the map elements that are deletion markers from the given value recursively.
+// This is needed when a value containing deletion markers is not merged with an existing one.
+func stripDeleteMarkers(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			e := mapIndex(v, key)
+			if isDeleteMarker(e) {
+				v.SetMapIndex(key, reflect.Value{})
+			} else {
+				stripDeleteMarkers(e)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+			if e.Kind() == reflect.Interface {
+				e = e.Elem()
+			}
+			stripDeleteMarkers(e)
+		}
+	}
+	return v
+}
+
+// copyValue returns a deep copy of the given value. Maps and slices are copied recursively
+// so that the copy shares no mutable data with the original value.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(copyValue(v.Elem()))
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMap(v.Type())
+		for _, key := range v.MapKeys() {
+			c.SetMapIndex(key, copyValue(v.MapIndex(key)))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Cap())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+	}
+	return v
+}
+
 // mapIndex returns an element value of a map at the specified index.
 // If the value is an interface, the underlying value will be returned.
 func mapIndex(mp reflect.Value, index reflect.Value) reflect.Value {
@@ -363,36 +689,64 @@ func getElement(v reflect.Value, p string) reflect.Value {
 	return reflect.Value{}
 }
 
+// element returns the element value of a map, array, or slice at the specified index.
+// Map keys are matched case-insensitively if the configuration is created with WithCaseInsensitiveKeys().
+func (c *Config) element(v reflect.Value, p string) reflect.Value {
+	e := getElement(v, p)
+	if e.IsValid() || !c.ignoreCase || v.Kind() != reflect.Map {
+		return e
+	}
+	if key := findKey(v, p); key.IsValid() {
+		return mapIndex(v, key)
+	}
+	return e
+}
+
+// findKey returns the key of a map that equals to the given name case-insensitively.
+// An invalid value is returned if no such key is found.
+func findKey(m reflect.Value, name string) reflect.Value {
+	for _, key := range m.MapKeys() {
+		if strings.EqualFold(keyString(key), name) {
+			return key
+		}
+	}
+	return reflect.Value{}
+}
+
 // setElement ses the element value of a map, array, or slice at the specified index.
 func setElement(data reflect.Value, p string, v interface{}) error {
 	value := reflect.ValueOf(v)
 	switch data.Kind() {
 	case reflect.Map:
 		key := reflect.ValueOf(p)
+		if !key.Type().AssignableTo(data.Type().Key()) {
+			return fmt.Errorf("%v cannot be used as a key of %v", p, data.Type())
+		}
+		if value.IsValid() && !value.Type().AssignableTo(data.Type().Elem()) {
+			return fmt.Errorf("%v cannot be used as an element of %v", value.Type(), data.Type())
+		}
 		data.SetMapIndex(key, value)
 	case reflect.Slice, reflect.Array:
 		idx, err := strconv.Atoi(p)
 		if err != nil || idx < 0 {
 			return fmt.Errorf("%v is not a valid array or slice index", p)
 		}
-		if data.Kind() == reflect.Slice {
-			if idx >= data.Cap() {
+		if idx >= data.Len() {
+			if data.Kind() == reflect.Slice {
 				return fmt.Errorf("%v is out of the slice index bound", p)
 			}
-			data.SetLen(idx + 1)
-		} else if idx >= data.Cap() {
 			return fmt.Errorf("%v is out of the array index bound", p)
 		}
-		data.Index(idx).Set(value)
+		e := data.Index(idx)
+		if !e.CanSet() {
+			return fmt.Errorf("%v is not a settable array element", p)
+		}
+		if !value.IsValid() {
+			value = reflect.Zero(e.Type())
+		} else if !value.Type().AssignableTo(e.Type()) {
+			return fmt.Errorf("%v cannot be used as an element of %v", value.Type(), data.Type())
+		}
+		e.Set(value)
 	}
 	return nil
 }
-
-func stripJSONComments(s []byte) ([]byte, error) {
-	var out bytes.Buffer
-	reader := bytes.NewBuffer(s)
-	if err := jsonpreprocess.WriteCommentTrimmedTo(&out, reader); err != nil {
-		return s, err
-	}
-	return out.Bytes(), nil
-}
@@ -217,6 +217,12 @@ func TestLoad(t *testing.T) {
 	}{
 		{"testdata/c1.json", "testdata/c2.json", `{"A1":"a1","A2":3,"A3":true,"A4":2.13,"A5":"a5","A6":{"B1":"b1","B2":{"C1":"c1","C2":"c2"}},"A7":["d1","d2"]}`},
 		{"testdata/c1.toml", "testdata/c2.toml", `{"A1":"a1","A2":3,"A3":true,"A4":2.13,"A5":"a5","A6":{"B1":"b1","B2":{"C1":"c1","C2":"c2"}},"A7":["d1","d2"]}`},
+		{"testdata/c1.json", "testdata/c2.yaml", `{"A1":"a1","A2":3,"A3":true,"A4":2.13,"A5":"a5","A6":{"B1":"b1","B2":{"C1":"c1","C2":"c2"}},"A7":["d1","d2"]}`},
+		{"testdata/c1.hcl", "testdata/c2.hcl", `{"A1":"a1","A2":3,"A3":true,"A4":2.13,"A5":"a5","A6":{"B1":"b1","B2":{"C1":"c1","C2":"c2"}},"A7":["d1","d2"]}`},
+		{"testdata/c1.xml", "testdata/c2.xml", `{"A1":"a1","A2":3,"A3":true,"A4":2.13,"A5":"a5","A6":{"B1":"b1","B2":{"C1":"c1","C2":"c2"}},"A7":["d1","d2"]}`},
+		{"testdata/c1.ini", "testdata/c2.ini", `{"A1":"a1","A2":3,"A3":true,"A4":2.13,"A5":"a5","A6":{"B1":"b1","B2":{"C1":"c1","C2":"c2"}},"A7":["d1","d2"]}`},
+		{"testdata/c1.env", "testdata/c2.env", `{"A1":"a1","A2":3,"A3":true,"A4":"2.13","A5":"a5","A6":{"B1":"b1","B2":{"C1":"c1","C2":"c2"}},"A7":["d1","d2"]}`},
+		{"testdata/c1.properties", "testdata/c2.properties", `{"A1":"a1","A2":3,"A3":true,"A4":2.13,"A5":"a5","A6":{"B1":"b1","B2":{"C1":"c1","C2":"c2"}},"A7":["d1","d2"]}`},
 	}
 	for _, test := range tests {
 		c := New()
@@ -228,6 +234,10 @@ func TestLoad(t *testing.T) {
 		if string(s) != test.expected {
 			t.Errorf(`Load(%q, %q), result is %v, expected %v`, test.f1, test.f2, string(s), test.expected)
 		}
+		var a7 []string
+		if err := c.Configure(&a7, "A7"); err != nil || len(a7) != 2 {
+			t.Errorf(`Load(%q, %q), Configure() = %v, %v`, test.f1, test.f2, a7, err)
+		}
 	}
 }
 
@@ -258,6 +268,31 @@ func TestLoadYamlFile(t *testing.T) {
 	}
 }
 
+func TestLoadWithDeleteMarkers(t *testing.T) {
+	tests := []struct {
+		f1, f2 string
+	}{
+		{"testdata/c1.json", "testdata/c4.json"},
+		{"testdata/c1.yaml", "testdata/c4.yaml"},
+	}
+	for _, test := range tests {
+		c := New()
+		if err := c.Load(test.f1, test.f2); err != nil {
+			t.Error(err)
+			continue
+		}
+		if v := c.Get("A3"); v != nil {
+			t.Errorf(`Load(%q, %q), Get("A3") = %v, expected nil`, test.f1, test.f2, v)
+		}
+		if v := c.Get("A6.B2"); v != nil {
+			t.Errorf(`Load(%q, %q), Get("A6.B2") = %v, expected nil`, test.f1, test.f2, v)
+		}
+		if v := c.Get("A6.B1"); v != "b1" {
+			t.Errorf(`Load(%q, %q), Get("A6.B1") = %v, expected %v`, test.f1, test.f2, v, "b1")
+		}
+	}
+}
+
 func TestJSONComment(t *testing.T) {
 	data := []byte(`{
 		/*
@@ -326,6 +361,26 @@ func TestMerge(t *testing.T) {
 			`{"a":[1,2]}`,
 			`{"a":[100,200]}`,
 			`{"a":[100,200]}`,
+		}, {
+			`{"a":1,"b":2}`,
+			`{"b":{"$delete":true}}`,
+			`{"a":1}`,
+		}, {
+			`{"a":{"b":1,"c":2},"d":3}`,
+			`{"a":{"c":{"$delete":true}},"e":{"$delete":true}}`,
+			`{"a":{"b":1},"d":3}`,
+		}, {
+			`{"a":1}`,
+			`{"b":{"c":{"$delete":true},"d":2}}`,
+			`{"a":1,"b":{"d":2}}`,
+		}, {
+			`{"a":1}`,
+			`{"$delete":true}`,
+			"null",
+		}, {
+			`{"a":1}`,
+			`{"a":{"$delete":false}}`,
+			`{"a":{"$delete":false}}`,
 		},
 	}
 
@@ -333,7 +388,7 @@ func TestMerge(t *testing.T) {
 		var v1, v2 interface{}
 		json.Unmarshal([]byte(test.base), &v1)
 		json.Unmarshal([]byte(test.update), &v2)
-		v := merge(reflect.ValueOf(v1), reflect.ValueOf(v2))
+		v := merge(reflect.ValueOf(v1), reflect.ValueOf(v2), false)
 		var s []byte
 		if v.IsValid() {
 			s, _ = json.Marshal(v.Interface())
@@ -345,3 +400,129 @@ func TestMerge(t *testing.T) {
 		}
 	}
 }
+
+func TestSetDataWithoutMutation(t *testing.T) {
+	d1 := map[string]interface{}{
+		"A": map[string]interface{}{"B": 1, "C": 2},
+		"D": []interface{}{1, 2},
+	}
+	d2 := map[string]interface{}{
+		"A": map[string]interface{}{"C": 3, "E": 4},
+	}
+
+	c1 := New()
+	c1.SetData(d1, d2)
+	c1.Set("A.F", 5)
+	if err := c1.Set("D.0", 10); err != nil {
+		t.Errorf(`Set("D.0", 10): %v`, err)
+	}
+	if v := c1.GetInt("D.0"); v != 10 {
+		t.Errorf(`Get("D.0") = %v, expected %v`, v, 10)
+	}
+	c2 := New()
+	c2.SetData(d1)
+
+	s, _ := json.Marshal(d1)
+	expected := `{"A":{"B":1,"C":2},"D":[1,2]}`
+	if string(s) != expected {
+		t.Errorf("SetData(d1, d2) modified d1 to %v, expected %v", string(s), expected)
+	}
+	s, _ = json.Marshal(c2.Data())
+	if string(s) != expected {
+		t.Errorf("SetData(d1) = %v, expected %v", string(s), expected)
+	}
+
+	value := map[string]interface{}{"X": 1}
+	c2.Set("A.G", value)
+	value["X"] = 2
+	if v := c2.GetInt("A.G.X"); v != 1 {
+		t.Errorf(`Get("A.G.X") = %v, expected %v`, v, 1)
+	}
+	if err := c2.Set("A.H", nil); err != nil {
+		t.Errorf(`Set("A.H", nil): %v`, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	c := New()
+	c.Register("T0", T0)
+	c.LoadJSON([]byte(`{"A": {"B": 1, "C": [1, 2]}}`))
+
+	clone := c.Clone()
+	clone.Set("A.B", 2)
+	clone.Set("A.D", 3)
+	clone.Register("T1", T0)
+
+	s, _ := json.Marshal(c.Data())
+	expected := `{"A":{"B":1,"C":[1,2]}}`
+	if string(s) != expected {
+		t.Errorf("Clone() modified the original data to %v, expected %v", string(s), expected)
+	}
+	s, _ = json.Marshal(clone.Data())
+	expected = `{"A":{"B":2,"C":[1,2],"D":3}}`
+	if string(s) != expected {
+		t.Errorf("Clone().Data() = %v, expected %v", string(s), expected)
+	}
+	if _, ok := clone.types["T0"]; !ok {
+		t.Errorf("Clone() did not copy the registered type T0")
+	}
+	if _, ok := c.types["T1"]; ok {
+		t.Errorf("Clone().Register() modified the original type registry")
+	}
+	if New().Clone().Data() != nil {
+		t.Errorf("New().Clone().Data() = %v, expected nil", New().Clone().Data())
+	}
+}
+
+func TestCaseInsensitiveKeys(t *testing.T) {
+	c := New(WithCaseInsensitiveKeys())
+	c.LoadJSON([]byte(`{"Database": {"Host": "db1", "Port": 5432}, "Name": "app"}`))
+	c.Load("testdata/c1.yaml")
+	c.LoadJSON([]byte(`{"database": {"host": "db2", "User": "root"}, "a1": "x"}`))
+
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"Database.Host", "db2"},
+		{"database.host", "db2"},
+		{"DATABASE.PORT", 5432.0},
+		{"Database.user", "root"},
+		{"A1", "x"},
+		{"a6.b2.c1", "c1"},
+		{"Database.Password", nil},
+	}
+	for _, test := range tests {
+		if v := c.Get(test.path); v != test.expected {
+			t.Errorf("Get(%q) = %v, expected %v", test.path, v, test.expected)
+		}
+	}
+
+	if err := c.Set("database.PORT", 5433); err != nil {
+		t.Errorf(`Set("database.PORT", 5433): %v`, err)
+	}
+	if err := c.Set("DATABASE.Options.Timeout", 10); err != nil {
+		t.Errorf(`Set("DATABASE.Options.Timeout", 10): %v`, err)
+	}
+	s, _ := json.Marshal(c.Get("Database"))
+	expected := `{"Host":"db2","Options":{"Timeout":10},"Port":5433,"User":"root"}`
+	if string(s) != expected {
+		t.Errorf(`Get("Database") = %v, expected %v`, string(s), expected)
+	}
+	if data := c.Data().(map[string]interface{}); data["database"] != nil || data["Name"] != "app" {
+		t.Errorf("Data() = %v, expected the original key spellings", data)
+	}
+
+	// keys are case-sensitive by default
+	c = New()
+	c.LoadJSON([]byte(`{"Database": {"Host": "db1"}}`), []byte(`{"database": {"host": "db2"}}`))
+	if v := c.Get("Database.Host"); v != "db1" {
+		t.Errorf(`Get("Database.Host") = %v, expected %v`, v, "db1")
+	}
+	if v := c.Get("DATABASE.HOST"); v != nil {
+		t.Errorf(`Get("DATABASE.HOST") = %v, expected nil`, v)
+	}
+	if v := New(WithCaseInsensitiveKeys()).Clone(); !v.ignoreCase {
+		t.Errorf("Clone() did not keep the case-insensitive option")
+	}
+}
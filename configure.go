@@ -8,19 +8,24 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 // ConfigValueError describes a configuration that cannot be used to configure a target value
 type ConfigValueError struct {
-	Path    string // path to the configuration value
-	Message string // the detailed error message
+	Path     string   // path to the configuration value
+	Message  string   // the detailed error message
+	Position Position // where the configuration value was defined, if known
 }
 
 // Error returns the error message represented by ConfigValueError
 func (e *ConfigValueError) Error() string {
 	path := strings.Trim(e.Path, ".")
+	if pos := e.Position.String(); pos != "" {
+		return fmt.Sprintf("%v: %q points to an inappropriate configuration value: %v", pos, path, e.Message)
+	}
 	return fmt.Sprintf("%q points to an inappropriate configuration value: %v", path, e.Message)
 }
 
@@ -53,20 +58,23 @@ func (e *ProviderError) Error() string {
 	if e.Value.Type().NumOut() != 1 {
 		return fmt.Sprintf("The provider should have a single output, got %v", e.Value.Type().NumOut())
 	}
+	if e.Value.Type().IsVariadic() {
+		return "The provider should not be variadic"
+	}
 	return ""
 }
 
 // Register associates a type name with a provider that creates an instance of the type.
-// The provider must be a function with a single output.
+// The provider must be a function with a single output, which may be a struct, a map, or any other value,
+// or a pointer to one.
 // Register is mainly needed when calling Configure() to configure an object and create
 // new instances of the specified types.
+//
+// The provider may have parameters, which are resolved with the registered instances and types
+// as described in Resolve(). A new instance is created every time the provider is needed.
+// Use RegisterScoped() to reuse the instances.
 func (c *Config) Register(name string, provider interface{}) error {
-	v := reflect.ValueOf(provider)
-	if v.Kind() != reflect.Func || v.Type().NumOut() != 1 {
-		return &ProviderError{v}
-	}
-	c.types[name] = v
-	return nil
+	return c.RegisterScoped(name, provider, Transient)
 }
 
 // Configure configures the specified value.
@@ -75,17 +83,114 @@ func (c *Config) Register(name string, provider interface{}) error {
 // by specifying the path to that part.
 //
 // To configure a struct, the configuration should be a map. A struct field will be assigned
-// with a map value whose key is the same as the field name. If a field is also struct,
-// it will be recursively configured with the corresponding map configuration.
+// with a map value whose key is the same as the field name (or differs only in case, if the
+// configuration is created with WithCaseInsensitiveKeys()). If a field is also struct,
+// it will be recursively configured with the corresponding map configuration. An error is returned
+// if a map key does not correspond to any field. Use ConfigureStrict() to check all keys before
+// a struct is modified.
+//
+// A struct field may be given options using the "config" tag, such as `config:",secret"`. The values of
+// the fields with the "secret" option in the value, and in the types registered by Register(), are masked
+// by Redacted() and String().
+//
+// The fields of an embedded struct are promoted as they are in Go, so they can be configured by the keys
+// in the map of the outer struct. The embedded struct can also be configured as a whole by its type name.
+// A nil pointer to an embedded struct is allocated when any of its fields is configured.
 //
 // When configuring an interface, the configuration should be a map with a special "type" key.
 // The "type" element specifies the type name registered by Register(). It allows the method
-// to create a correct object given a type name.
+// to create a correct object given a type name. A different key may be used by creating the
+// configuration with WithTypeKey(), or for a field by the "typekey" option, e.g. `config:",typekey=kind"`.
+// If the created object is a struct or a map, it is configured with the other elements of the map.
+// Otherwise, it is configured with the "value" element, e.g. {"type": "level", "value": 3}.
+//
+// Secret references in the configuration are resolved before they are used to configure the value.
+//
+// After the value is configured, it is validated according to the "validate" tags of the struct fields,
+// such as `validate:"min=1,max=65535"`, and the Validate() method of every value implementing Validator
+// is called. Please refer to Validator for more details.
 //
 // Note that the value to be configured must be passed in as a pointer.
 // You may specify a path to use a particular part of the configuration to configure
 // the value. If a path is not specified, the whole configuration will be used.
-func (c *Config) Configure(v interface{}, path ...string) (err error) {
+//
+// Configure() and ConfigureStrict() may be called concurrently, as long as the configuration is not modified.
+func (c *Config) Configure(v interface{}, path ...string) error {
+	return (&configurer{Config: c}).run(v, path)
+}
+
+// ConfigureStrict configures the specified value in the same way as Configure(), but checks the keys more strictly.
+//
+// Before anything is configured, all keys of the maps used to configure structs, including the nested ones,
+// are checked, and an error is returned if any of them does not correspond to a settable struct field (except
+// the type key). The keys are checked in sorted order, so the same error is returned for the same configuration.
+// Unlike Configure(), the value is not modified if the check fails.
+//
+// To find the configuration values that are never used by any call of Configure() or ConfigureStrict(),
+// such as misspelled keys in a configuration file for an object configured with a path, call Unused().
+func (c *Config) ConfigureStrict(v interface{}, path ...string) error {
+	return (&configurer{Config: c, strict: true}).run(v, path)
+}
+
+// Unused returns the paths of the configuration values that have not been used to configure any value
+// by Configure() or ConfigureStrict(), ordered by the map keys and array indexes along the paths.
+//
+// A map or array is used if it configures a value as a whole, such as an interface{} field. Otherwise,
+// its elements are reported separately, and an empty map or array is reported if it is not used.
+func (c *Config) Unused() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	paths := []string{}
+	var walk func(path string, v reflect.Value)
+	walk = func(path string, v reflect.Value) {
+		for v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if c.used[c.sourceKey(path)] {
+			return
+		}
+		if (v.Kind() == reflect.Map || isArray(v)) && v.Len() > 0 {
+			eachElement(v, func(key string, e reflect.Value) {
+				walk(joinPath(path, key), e)
+			})
+		} else if path != "" || v.IsValid() {
+			paths = append(paths, path)
+		}
+	}
+	walk("", c.data)
+	return paths
+}
+
+// configurer configures a value with the configuration data in a call of Configure() or ConfigureStrict().
+type configurer struct {
+	*Config
+	strict     bool                     // whether the keys are checked strictly
+	scoped     map[string]reflect.Value // the instances of the types registered with the PerConfigure scope
+	building   []string                 // the names of the types being created, for detecting dependency cycles
+	configured map[pointerKey]bool      // the addressable values that have been configured
+	validated  map[pointerKey]bool      // the values that have been validated
+	usedPaths  map[string]bool          // the source keys of the values used in this call
+}
+
+// pointerKey identifies an addressable value by its address and type.
+type pointerKey struct {
+	ptr uintptr
+	t   reflect.Type
+}
+
+// setConfigured records the value as being configured if it is addressable.
+func (c *configurer) setConfigured(v reflect.Value) {
+	if !v.CanAddr() {
+		return
+	}
+	if c.configured == nil {
+		c.configured = map[pointerKey]bool{}
+	}
+	c.configured[pointerKey{v.Addr().Pointer(), v.Type()}] = true
+}
+
+// run configures the value with the configuration data located by the optional path.
+func (c *configurer) run(v interface{}, path []string) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if _, ok := r.(runtime.Error); ok {
@@ -95,52 +200,104 @@ func (c *Config) Configure(v interface{}, path ...string) (err error) {
 		}
 	}()
 
+	defer c.record()
+
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &ConfigTargetError{rv}
 	}
 
 	p := ""
+	var keys []string
 	config := c.data
 	if len(path) > 0 {
-		d := c.Get(path[0])
-		if d == nil {
-			return &ConfigPathError{path[0], "no configuration value was found"}
+		if config, err = c.value(path[0]); err != nil {
+			return c.pathError(path[0], err.Error())
 		}
-		p = path[0]
-		config = reflect.ValueOf(d)
+		if !config.IsValid() {
+			return c.pathError(path[0], "no configuration value was found")
+		}
+		keys, _ = parsePath(path[0])
+		p = PathOf(keys...)
+	}
+	c.addSecretFields(keys, rv.Elem().Type())
+	if config, err = c.resolveSecrets(config, p); err != nil {
+		return err
 	}
 
-	return c.configure(rv, config, p)
+	if c.strict {
+		if err = c.checkKeys(rv.Elem(), config, p, c.typeKey); err != nil {
+			return err
+		}
+	}
+	if err = c.configure(rv.Elem(), config, p, c.typeKey); err != nil {
+		return err
+	}
+	return c.validate(rv, p)
+}
+
+// use marks the configuration value at the path as used.
+func (c *configurer) use(path string) {
+	if c.usedPaths == nil {
+		c.usedPaths = map[string]bool{}
+	}
+	c.usedPaths[c.sourceKey(path)] = true
 }
 
-// configure configures the value with the configuration.
-func (c *Config) configure(v, config reflect.Value, path string) error {
+// record adds the paths used in this call to the configuration, so that concurrent calls
+// do not update the map of the configuration at the same time.
+func (c *configurer) record() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.usedPaths {
+		c.used[key] = true
+	}
+}
+
+// configure configures the value with the configuration. The type key is the key of the type element
+// in the configuration maps used to configure interfaces.
+func (c *configurer) configure(v, config reflect.Value, path, typeKey string) error {
+	for config.Kind() == reflect.Interface || config.Kind() == reflect.Ptr {
+		config = config.Elem()
+	}
+	config, done, err := c.decode(v, config, path)
+	if err != nil {
+		return err
+	}
+	if done {
+		c.use(path)
+		return nil
+	}
+	for config.Kind() == reflect.Ptr {
+		config = config.Elem()
+	}
+
 	// get the concrete value, may allocate space if needed
 	v = indirect(v)
 
 	if !v.IsValid() {
 		return nil
 	}
-	for config.Kind() == reflect.Interface || config.Kind() == reflect.Ptr {
-		config = config.Elem()
+	c.setConfigured(v)
+	if (config.Kind() != reflect.Map && !isArray(config)) || config.Len() == 0 {
+		c.use(path)
 	}
 
 	switch config.Kind() {
 	case reflect.Array, reflect.Slice:
-		return c.configureArray(v, config, path)
+		return c.configureArray(v, config, path, typeKey)
 	case reflect.Map:
 		switch v.Kind() {
 		case reflect.Interface:
-			return c.configureInterface(v, config, path)
+			return c.configureInterface(v, config, path, typeKey)
 		case reflect.Struct:
-			return c.configureStruct(v, config, path)
+			return c.configureStruct(v, config, path, typeKey)
 		case reflect.Map:
-			return c.configureMap(v, config, path)
+			return c.configureMap(v, config, path, typeKey)
 		default:
-			return &ConfigValueError{path, "a map cannot be used to configure " + v.Type().String()}
+			return c.valueError(path, "a map cannot be used to configure "+v.Type().String())
 		}
-		return c.configureMap(v, config, path)
+		return c.configureMap(v, config, path, typeKey)
 	default:
 		return c.configureScalar(v, config, path)
 	}
@@ -148,17 +305,18 @@ func (c *Config) configure(v, config reflect.Value, path string) error {
 	return nil
 }
 
-func (c *Config) configureArray(v, config reflect.Value, path string) error {
+func (c *configurer) configureArray(v, config reflect.Value, path, typeKey string) error {
 	vkind := v.Kind()
 
 	// nil interface
 	if vkind == reflect.Interface && v.NumMethod() == 0 {
 		v.Set(config)
+		c.use(path)
 		return nil
 	}
 
 	if vkind != reflect.Array && vkind != reflect.Slice {
-		return &ConfigValueError{path, fmt.Sprintf("%v cannot be used to configure %v", config.Type(), v.Type())}
+		return c.valueError(path, fmt.Sprintf("%v cannot be used to configure %v", config.Type(), v.Type()))
 	}
 
 	n := config.Len()
@@ -174,7 +332,7 @@ func (c *Config) configureArray(v, config reflect.Value, path string) error {
 		n = v.Cap()
 	}
 	for i := 0; i < n; i++ {
-		if err := c.configure(v.Index(i), config.Index(i), path+"."+strconv.Itoa(i)); err != nil {
+		if err := c.configure(v.Index(i), config.Index(i), joinPath(path, strconv.Itoa(i)), typeKey); err != nil {
 			return err
 		}
 	}
@@ -194,7 +352,7 @@ func (c *Config) configureArray(v, config reflect.Value, path string) error {
 	return nil
 }
 
-func (c *Config) configureMap(v, config reflect.Value, path string) error {
+func (c *configurer) configureMap(v, config reflect.Value, path, typeKey string) error {
 	// map must have string kind
 	t := v.Type()
 	if v.IsNil() {
@@ -204,7 +362,7 @@ func (c *Config) configureMap(v, config reflect.Value, path string) error {
 	for _, k := range config.MapKeys() {
 		elemType := v.Type().Elem()
 		mapElem := reflect.New(elemType).Elem()
-		if err := c.configure(mapElem, mapIndex(config, k), path+"."+k.String()); err != nil {
+		if err := c.configure(mapElem, mapIndex(config, k), joinPath(path, keyString(k)), typeKey); err != nil {
 			return err
 		}
 		v.SetMapIndex(k.Convert(v.Type().Key()), mapElem)
@@ -213,29 +371,26 @@ func (c *Config) configureMap(v, config reflect.Value, path string) error {
 	return nil
 }
 
-// the "type" field name
-var typeKey = reflect.ValueOf("type")
-
-func (c *Config) configureStruct(v, config reflect.Value, path string) error {
+// configureStruct configures the struct with the map, skipping the type element keyed by the type key.
+func (c *configurer) configureStruct(v, config reflect.Value, path, typeKey string) error {
 	for _, k := range config.MapKeys() {
-		if k.String() == typeKey.String() {
+		name := keyString(k)
+		if name == typeKey {
 			continue
 		}
-		path = path + "." + k.String()
-		field := v.FieldByName(k.Interface().(string))
+		p := joinPath(path, name)
+		field, options := c.structField(v, name, true)
 		if !field.IsValid() {
-			return &ConfigValueError{path, fmt.Sprintf("field %v not found in struct %v", k.String(), v.Type())}
+			return c.valueError(p, fmt.Sprintf("field %v not found in struct %v", name, v.Type()))
 		}
 		if !field.CanSet() {
-			return &ConfigValueError{path, fmt.Sprintf("field %v cannot be set", k.String())}
+			return c.valueError(p, fmt.Sprintf("field %v cannot be set", name))
 		}
-		if field.Kind() == reflect.Ptr {
-			if field.IsNil() {
-				field.Set(reflect.New(field.Type().Elem()))
-			}
-			field = field.Elem()
+		fieldTypeKey := c.typeKey
+		if key, ok := options["typekey"]; ok && key != "" {
+			fieldTypeKey = key
 		}
-		if err := c.configure(field, mapIndex(config, k), path); err != nil {
+		if err := c.configure(field, mapIndex(config, k), p, fieldTypeKey); err != nil {
 			return err
 		}
 	}
@@ -243,38 +398,348 @@ func (c *Config) configureStruct(v, config reflect.Value, path string) error {
 	return nil
 }
 
-func (c *Config) configureInterface(v, config reflect.Value, path string) error {
+// checkKeys checks the configuration used to configure the value without modifying the value. It returns
+// an error if a key of a map used to configure a struct does not correspond to a settable field of the struct,
+// or if a map used to configure a registered type that is neither a struct nor a map has an element other than
+// the type and "value" elements. The nested configuration values are checked against the values they configure,
+// using temporary values in place of nil pointers, new slice and map elements, and new instances of the registered
+// types. The keys of every map are checked in sorted order. The other errors are left to configure().
+func (c *configurer) checkKeys(v, config reflect.Value, path, typeKey string) error {
+	for config.Kind() == reflect.Interface || config.Kind() == reflect.Ptr {
+		config = config.Elem()
+	}
+	config, direct, err := c.convert(v, config, path)
+	if err != nil || direct {
+		return err
+	}
+	for config.Kind() == reflect.Ptr {
+		config = config.Elem()
+	}
+
+	v = peek(v)
+	switch config.Kind() {
+	case reflect.Array, reflect.Slice:
+		if v.Kind() != reflect.Array && v.Kind() != reflect.Slice {
+			return nil
+		}
+		for i := 0; i < config.Len(); i++ {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if i < v.Len() {
+				e = v.Index(i)
+			} else if v.Kind() == reflect.Array {
+				break
+			}
+			if err := c.checkKeys(e, config.Index(i), joinPath(path, strconv.Itoa(i)), typeKey); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		switch v.Kind() {
+		case reflect.Interface:
+			return c.checkInstance(v, config, path, typeKey)
+		case reflect.Struct:
+			return c.checkFields(v, config, path, typeKey)
+		case reflect.Map:
+			return c.checkElements(v, config, path, typeKey)
+		}
+	}
+	return nil
+}
+
+// checkFields checks if every key of the map except the type key corresponds to a settable field of the struct,
+// and checks the elements of the map against the fields.
+func (c *configurer) checkFields(v, config reflect.Value, path, typeKey string) error {
+	keys := mapKeys(config)
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == typeKey {
+			continue
+		}
+		p := joinPath(path, name)
+		field, options := c.structField(v, name, false)
+		if !field.IsValid() || !field.CanSet() {
+			return c.valueError(p, fmt.Sprintf("no settable field corresponds to the key %q in struct %v", name, v.Type()))
+		}
+		fieldTypeKey := c.typeKey
+		if key, ok := options["typekey"]; ok && key != "" {
+			fieldTypeKey = key
+		}
+		if err := c.checkKeys(field, mapIndex(config, keys[name]), p, fieldTypeKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkElements checks the elements of the map used to configure a map value against the new elements of the value.
+func (c *configurer) checkElements(v, config reflect.Value, path, typeKey string) error {
+	var err error
+	eachElement(config, func(name string, e reflect.Value) {
+		if err == nil {
+			err = c.checkKeys(reflect.New(v.Type().Elem()).Elem(), e, joinPath(path, name), typeKey)
+		}
+	})
+	return err
+}
+
+// checkInstance checks the map used to configure the interface against a new instance of the type specified
+// by the type element. The instance is a zero value of the type created by the provider, which is not called.
+func (c *configurer) checkInstance(v, config reflect.Value, path, typeKey string) error {
+	if v.NumMethod() == 0 {
+		return nil
+	}
+	tk := mapIndex(config, reflect.ValueOf(typeKey))
+	if tk.Kind() != reflect.String {
+		return nil
+	}
+	builder, ok := c.types[tk.String()]
+	if !ok {
+		return nil
+	}
+	s := peek(reflect.New(builder.Type().Out(0)).Elem())
+	switch s.Kind() {
+	case reflect.Struct:
+		return c.checkFields(s, config, path, typeKey)
+	case reflect.Map:
+		return c.checkElements(s, withoutKey(config, typeKey), path, c.typeKey)
+	}
+	var err error
+	eachElement(config, func(name string, e reflect.Value) {
+		if err != nil || name == typeKey {
+			return
+		}
+		if name != valueKey.String() {
+			err = c.valueError(joinPath(path, name), fmt.Sprintf("only the %v and %v elements are allowed for %v", typeKey, valueKey, s.Type()))
+		} else {
+			err = c.checkKeys(s, e, joinPath(path, name), c.typeKey)
+		}
+	})
+	return err
+}
+
+// structField returns the field of a struct corresponding to the given configuration key,
+// together with the options in the "config" tag of the field. An invalid value is returned
+// if the field is not found.
+//
+// A field corresponds to the key if its name is the same as the key.
+//
+// The fields of embedded structs are promoted as they are in Go: an embedded struct may be configured
+// either as a whole by its type name, or field by field from the map of the outer struct. A field hides
+// the fields with the same key in the structs embedded more deeply, and the fields with the same key
+// at the same depth are ambiguous and do not correspond to the key.
+//
+// If alloc is true, nil pointers to the embedded structs containing the field are allocated. Otherwise,
+// a temporary value is returned for a field in such a struct, which is settable only if the struct can be
+// allocated.
+func (c *Config) structField(v reflect.Value, key string, alloc bool) (reflect.Value, map[string]string) {
+	f, ok := c.fieldOf(v.Type(), key)
+	if !ok {
+		return reflect.Value{}, nil
+	}
+	return fieldByIndex(v, f.Index, alloc), parseTag(f)
+}
+
+// fieldOf returns the field of a struct type corresponding to the given configuration key, as described
+// in structField(). The index of the returned field is the index sequence of the field in the struct type.
+func (c *Config) fieldOf(t reflect.Type, key string) (reflect.StructField, bool) {
+	match := func(name string) bool {
+		return name == key || c.ignoreCase && strings.EqualFold(name, key)
+	}
+
+	type embedded struct {
+		t     reflect.Type
+		index []int
+	}
+	current := []embedded{{t, nil}}
+	visited := map[reflect.Type]bool{}
+	for len(current) > 0 {
+		var next []embedded
+		var named []reflect.StructField
+		for _, e := range current {
+			if visited[e.t] {
+				continue
+			}
+			visited[e.t] = true
+			for i := 0; i < e.t.NumField(); i++ {
+				f := e.t.Field(i)
+				f.Index = append(append([]int{}, e.index...), i)
+				if match(f.Name) {
+					named = append(named, f)
+				}
+				if isEmbeddedStruct(f) {
+					next = append(next, embedded{embeddedType(f), f.Index})
+				}
+			}
+		}
+		if len(named) == 1 {
+			return named[0], true
+		}
+		if len(named) > 0 {
+			break
+		}
+		current = next
+	}
+	return reflect.StructField{}, false
+}
+
+// configurableFields returns the fields of a struct type that correspond to configuration keys, including
+// the fields promoted from the embedded structs. The index of each field is its index sequence in the struct type.
+func (c *Config) configurableFields(root reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	visited := map[reflect.Type]bool{}
+	var collect func(t reflect.Type, index []int)
+	collect = func(t reflect.Type, index []int) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			f.Index = append(append([]int{}, index...), i)
+			if isEmbeddedStruct(f) {
+				collect(embeddedType(f), f.Index)
+				continue
+			}
+			// skip the fields hidden by others or ambiguous with others
+			if g, ok := c.fieldOf(root, f.Name); ok && reflect.DeepEqual(g.Index, f.Index) {
+				fields = append(fields, f)
+			}
+		}
+	}
+	collect(root, nil)
+	return fields
+}
+
+// fieldByIndex returns the nested field of a struct corresponding to the index sequence,
+// allocating nil pointers to the embedded structs along the way if alloc is true.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Zero(v.Type().Elem().FieldByIndex(index[i:]).Type)
+				}
+				if !alloc {
+					return fieldByIndex(reflect.New(v.Type().Elem()).Elem(), index[i:], true)
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// parseTag parses the "config" tag of a struct field. The tag consists of comma-separated options
+// following a leading comma, such as `config:",secret,typekey=kind"`.
+// The options are returned as a map from the option names to their values.
+func parseTag(field reflect.StructField) map[string]string {
+	parts := strings.Split(field.Tag.Get("config"), ",")
+	options := make(map[string]string)
+	for _, option := range parts[1:] {
+		if i := strings.IndexByte(option, '='); i >= 0 {
+			options[option[:i]] = option[i+1:]
+		} else if option != "" {
+			options[option] = ""
+		}
+	}
+	return options
+}
+
+// isEmbeddedStruct checks if the struct field is an embedded struct or a pointer to an embedded struct.
+func isEmbeddedStruct(f reflect.StructField) bool {
+	return f.Anonymous && embeddedType(f).Kind() == reflect.Struct
+}
+
+// embeddedType returns the type of an embedded field, or the type it points to if it is a pointer.
+func embeddedType(f reflect.StructField) reflect.Type {
+	if f.Type.Kind() == reflect.Ptr {
+		return f.Type.Elem()
+	}
+	return f.Type
+}
+
+// the key of the element holding the value of a registered type that is neither a struct nor a map
+var valueKey = reflect.ValueOf("value")
+
+// configureInterface configures the interface with a new instance of the type specified by the type element,
+// which is keyed by the type key in the map.
+//
+// A struct or a map created for the type is configured with the other elements of the map. A value of any
+// other type is configured with the "value" element.
+func (c *configurer) configureInterface(v, config reflect.Value, path, typeKey string) error {
 	// nil interface
 	if v.NumMethod() == 0 {
 		v.Set(config)
+		c.use(path)
 		return nil
 	}
 
-	tk := mapIndex(config, typeKey)
+	tk := mapIndex(config, reflect.ValueOf(typeKey))
 	if !tk.IsValid() {
-		return &ConfigValueError{path, "missing the type element"}
+		return c.valueError(path, fmt.Sprintf("missing the %v element", typeKey))
 	}
 	if tk.Kind() != reflect.String {
-		return &ConfigValueError{path, "type must be a string"}
+		return c.valueError(joinPath(path, typeKey), "type must be a string")
 	}
 
 	builder, ok := c.types[tk.String()]
 	if !ok {
-		return &ConfigValueError{path, fmt.Sprintf("type %q is unknown", tk.String())}
+		return c.valueError(joinPath(path, typeKey), fmt.Sprintf("type %q is unknown", tk.String()))
+	}
+	instance, err := c.build(tk.String())
+	if err != nil {
+		return c.valueError(joinPath(path, typeKey), err.Error())
 	}
 
-	object := builder.Call([]reflect.Value{})[0]
+	// keep the instance in an addressable value so that it can be configured and its address can be taken
+	object := reflect.New(builder.Type().Out(0)).Elem()
+	object.Set(instance)
+	value := object
+	if !object.Type().Implements(v.Type()) {
+		if !object.Addr().Type().Implements(v.Type()) {
+			return c.valueError(path, fmt.Sprintf("%v does not implement %v", object.Type(), v.Type()))
+		}
+		value = object.Addr()
+	}
+	c.use(joinPath(path, typeKey))
 
 	s := indirect(object)
-	if !s.Addr().Type().Implements(v.Type()) {
-		return &ConfigValueError{path, fmt.Sprintf("%v does not implement %v", s.Type(), v.Type())}
+	c.setConfigured(s)
+	switch s.Kind() {
+	case reflect.Struct:
+		err = c.configureStruct(s, config, path, typeKey)
+	case reflect.Map:
+		err = c.configureMap(s, withoutKey(config, typeKey), path, c.typeKey)
+	default:
+		if e := mapIndex(config, valueKey); e.IsValid() {
+			err = c.configure(s, e, joinPath(path, valueKey.String()), c.typeKey)
+		}
+	}
+	if err != nil {
+		return err
 	}
-	v.Set(object)
+	v.Set(value)
+	return nil
+}
 
-	return c.configureStruct(s, config, path)
+// withoutKey returns a copy of the map without the element with the given key.
+func withoutKey(m reflect.Value, key string) reflect.Value {
+	result := reflect.MakeMap(m.Type())
+	for _, k := range m.MapKeys() {
+		if keyString(k) != key {
+			result.SetMapIndex(k, m.MapIndex(k))
+		}
+	}
+	return result
 }
 
-func (c *Config) configureScalar(v, config reflect.Value, path string) error {
+func (c *configurer) configureScalar(v, config reflect.Value, path string) error {
 	if !config.IsValid() {
 		switch v.Kind() {
 		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
@@ -295,7 +760,28 @@ func (c *Config) configureScalar(v, config reflect.Value, path string) error {
 		return nil
 	}
 
-	return &ConfigValueError{path, fmt.Sprintf("%v cannot be used to configure %v", config.Type(), v.Type())}
+	return c.valueError(path, fmt.Sprintf("%v cannot be used to configure %v", config.Type(), v.Type()))
+}
+
+// peek returns the concrete value that indirect() returns for the value, without allocating nil pointers.
+// A nil pointer is followed to a temporary zero value of the type it points to.
+func peek(v reflect.Value) reflect.Value {
+	for {
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Ptr && !e.IsNil() {
+				v = e
+				continue
+			}
+		}
+		if v.Kind() != reflect.Ptr {
+			return v
+		}
+		if v.IsNil() {
+			v = reflect.New(v.Type().Elem())
+		}
+		v = v.Elem()
+	}
 }
 
 func indirect(v reflect.Value) reflect.Value {
@@ -6,6 +6,9 @@ package config
 
 import (
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -371,3 +374,403 @@ func TestConfigureYamlConfigure(t *testing.T) {
 		t.Errorf("D.E2=%q, expected %q", object.(*D).E2, "abc")
 	}
 }
+
+func TestConfigureCaseInsensitive(t *testing.T) {
+	data := []byte(`{"database": {"host": "db1", "PORT": 5432}, "name": "app"}`)
+	var obj struct {
+		Name     string
+		Database struct {
+			Host string
+			Port int
+		}
+	}
+
+	c := New(WithCaseInsensitiveKeys())
+	c.LoadJSON(data)
+	if err := c.Configure(&obj); err != nil {
+		t.Errorf("Configure(%v): %v", string(data), err)
+		return
+	}
+	if obj.Name != "app" || obj.Database.Host != "db1" || obj.Database.Port != 5432 {
+		t.Errorf("Configure(%v) = %+v", string(data), obj)
+	}
+
+	c = New()
+	c.LoadJSON(data)
+	if err := c.Configure(&obj); err == nil {
+		t.Errorf("Configure(%v) expected an error, got nil", string(data))
+	}
+}
+
+func TestConfigureWithTag(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte(`{"Host": "localhost", "Port": 3306}`))
+	var obj struct {
+		Host string
+		Port int `config:",secret"`
+	}
+	if err := c.Configure(&obj); err != nil {
+		t.Errorf("Configure(): %v", err)
+	} else if obj.Host != "localhost" || obj.Port != 3306 {
+		t.Errorf("Configure() = %+v", obj)
+	}
+
+	// the part of the tag before the comma does not rename the field
+	var obj2 struct {
+		Host string `config:"db_host"`
+	}
+	c.SetData(map[string]interface{}{"db_host": "localhost"})
+	if err := c.Configure(&obj2); err == nil {
+		t.Errorf("Configure() expected an error for %q, got nil", "db_host")
+	}
+}
+
+func TestConfigureStrict(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte(`{"Database": {"Host": "db1", "Port": 5432}, "Databse": {"Port": 3306}, "Name": "app"}`))
+	var db struct {
+		Host string
+		Port int
+	}
+	if err := c.ConfigureStrict(&db, "Database"); err != nil {
+		t.Errorf("ConfigureStrict(): %v", err)
+	} else if db.Host != "db1" || db.Port != 5432 {
+		t.Errorf("ConfigureStrict() = %+v", db)
+	}
+
+	var app struct {
+		Name     string
+		Database struct {
+			Host string
+			Port int
+			pass string
+		}
+		Extra string
+	}
+	err := c.ConfigureStrict(&app)
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "Databse" {
+		t.Errorf("ConfigureStrict() returned %v, expected a ConfigValueError for %q", err, "Databse")
+	}
+	if app.Name != "" {
+		t.Errorf("ConfigureStrict() modified the struct before checking the keys: %+v", app)
+	}
+
+	c.LoadJSON([]byte(`{"Database": {"Pass": "x"}}`))
+	err = c.ConfigureStrict(&app.Database, "Database")
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "Database.Pass" {
+		t.Errorf("ConfigureStrict() returned %v, expected a ConfigValueError for %q", err, "Database.Pass")
+	}
+
+	// the nested maps are checked before anything is configured
+	c.Register("D", func() *D {
+		return &D{}
+	})
+	var nested struct {
+		Name    string
+		Servers []struct{ Host string }
+		Backups map[string]struct{ Host string }
+		Plugin  C
+	}
+	tests := []struct {
+		json, path string
+	}{
+		{`{"Name": "app", "Servers": [{"Host": "a"}, {"Hots": "b"}]}`, "Servers.1.Hots"},
+		{`{"Name": "app", "Backups": {"x": {"Host": "a", "Port": 1}}}`, "Backups.x.Port"},
+		{`{"Name": "app", "Plugin": {"type": "D", "E1": "a", "E3": "b"}}`, "Plugin.E3"},
+	}
+	for _, test := range tests {
+		c.SetData()
+		c.LoadJSON([]byte(test.json))
+		err := c.ConfigureStrict(&nested)
+		if e, ok := err.(*ConfigValueError); !ok || e.Path != test.path {
+			t.Errorf("ConfigureStrict(%v) returned %v, expected a ConfigValueError for %q", test.json, err, test.path)
+		}
+		if nested.Name != "" || nested.Servers != nil || nested.Backups != nil || nested.Plugin != nil {
+			t.Errorf("ConfigureStrict(%v) modified the struct before checking the keys: %+v", test.json, nested)
+		}
+	}
+}
+
+func TestUnused(t *testing.T) {
+	c := New()
+	c.Register("D", func() *D {
+		return &D{}
+	})
+	c.LoadJSON([]byte(`{
+		"Database": {"Host": "db1", "Port": 5432},
+		"Databse": {"Port": 3306},
+		"Plugin": {"type": "D", "E1": "x"},
+		"Options": {"a": [1, 2]},
+		"Tags": [],
+		"Log": {"Level": "info", "Files": ["a.log", "b.log"]}
+	}`))
+	if unused := c.Unused(); len(unused) != 11 {
+		t.Errorf("Unused() = %v, expected all %v values", unused, 11)
+	}
+
+	var db struct {
+		Host string
+		Port int
+	}
+	var plugin C
+	var options interface{}
+	var level string
+	c.Configure(&db, "Database")
+	c.Configure(&plugin, "Plugin")
+	c.Configure(&options, "Options")
+	c.Configure(&level, "Log.Level")
+	expected := []string{"Databse.Port", "Log.Files.0", "Log.Files.1", "Tags"}
+	if unused := c.Unused(); !reflect.DeepEqual(unused, expected) {
+		t.Errorf("Unused() = %v, expected %v", unused, expected)
+	}
+	if unused := c.Clone().Unused(); !reflect.DeepEqual(unused, expected) {
+		t.Errorf("Clone().Unused() = %v, expected %v", unused, expected)
+	}
+
+	if unused := New().Unused(); len(unused) != 0 {
+		t.Errorf("Unused() = %v for an empty configuration, expected none", unused)
+	}
+}
+
+func TestConfigureConcurrently(t *testing.T) {
+	c := New()
+	c.RegisterScoped("logger", func() *testLog {
+		return &testLog{}
+	}, Singleton)
+	c.LoadJSON([]byte(`{"Log": {"type": "logger", "Prefix": "> "}, "Key": "abc", "Name": "app"}`))
+
+	logs := make([]testLogger, 10)
+	var wg sync.WaitGroup
+	for i := range logs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var app struct {
+				Log  testLogger
+				Key  string `config:",secret"`
+				Name string
+			}
+			if err := c.Configure(&app); err != nil {
+				t.Errorf("Configure(): %v", err)
+			}
+			logs[i] = app.Log
+			c.Unused()
+			_ = c.String()
+		}(i)
+	}
+	wg.Wait()
+
+	for _, log := range logs {
+		if log != logs[0] {
+			t.Errorf("Configure() created more than one singleton: %v, %v", log, logs[0])
+		}
+	}
+	if unused := c.Unused(); len(unused) != 0 {
+		t.Errorf("Unused() = %v, expected none", unused)
+	}
+	if s := c.String(); strings.Contains(s, "abc") {
+		t.Errorf("String() = %v, expected the secret to be masked", s)
+	}
+}
+
+type EmbedBase struct {
+	Host    string
+	Port    int `validate:"min=1"`
+	Timeout int
+}
+
+type EmbedAuth struct {
+	User    string
+	Timeout int
+}
+
+type embedLog struct {
+	Level string
+}
+
+type embedOuter struct {
+	EmbedBase
+	*EmbedAuth
+	embedLog
+	*embedLog2
+	C
+	Name string
+}
+
+type embedLog2 struct {
+	File string
+}
+
+func TestConfigureEmbedded(t *testing.T) {
+	c := New()
+	c.Register("D", func() *D {
+		return &D{}
+	})
+
+	// promoted fields are configured from the map of the outer struct
+	c.LoadJSON([]byte(`{"Host": "h", "Port": 80, "User": "u", "Level": "info", "C": {"type": "D", "E1": "e"}, "Name": "n"}`))
+	var v embedOuter
+	if err := c.Configure(&v); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	if v.Host != "h" || v.Port != 80 || v.Level != "info" || v.Name != "n" {
+		t.Errorf("Configure() = %+v", v)
+	}
+	if v.EmbedAuth == nil || v.User != "u" {
+		t.Errorf("Configure() did not allocate the embedded pointer: %+v", v.EmbedAuth)
+	}
+	if d, ok := v.C.(*D); !ok || d.E1 != "e" {
+		t.Errorf("Configure() = %#v for the embedded interface, expected a *D", v.C)
+	}
+
+	// an embedded struct may be configured by its type name
+	c.SetData(map[string]interface{}{"EmbedBase": map[string]interface{}{"Host": "x", "Port": 81}, "EmbedAuth": map[string]interface{}{"User": "y"}})
+	var v2 embedOuter
+	if err := c.Configure(&v2); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	if v2.Host != "x" || v2.Port != 81 || v2.EmbedAuth == nil || v2.User != "y" {
+		t.Errorf("Configure() = %+v", v2)
+	}
+
+	// the fields with the same key at the same depth are ambiguous, and unexported nil pointers cannot be allocated
+	tests := []struct {
+		data map[string]interface{}
+		path string
+	}{
+		{map[string]interface{}{"Timeout": 1}, "Timeout"},
+		{map[string]interface{}{"File": "a.log"}, "File"},
+		{map[string]interface{}{"embedLog": map[string]interface{}{"Level": "x"}}, "embedLog"},
+		{map[string]interface{}{"Port": 0}, "Port"},
+	}
+	for _, test := range tests {
+		c.SetData(test.data)
+		var v embedOuter
+		err := c.Configure(&v)
+		if e, ok := err.(*ConfigValueError); !ok || e.Path != test.path {
+			t.Errorf("Configure(%v) returned %v, expected a ConfigValueError for %q", test.data, err, test.path)
+		}
+	}
+
+	// strict configuration does not allocate the embedded pointers before the keys are checked
+	c.SetData(map[string]interface{}{"User": "u", "Usr": "x"})
+	var v3 embedOuter
+	if err := c.ConfigureStrict(&v3); err == nil {
+		t.Errorf("ConfigureStrict() expected an error for %q, got nil", "Usr")
+	}
+	if v3.EmbedAuth != nil {
+		t.Errorf("ConfigureStrict() allocated the embedded pointer before checking the keys")
+	}
+}
+
+type namer interface {
+	Name() string
+}
+
+type nameList []string
+
+func (l nameList) Name() string {
+	return strings.Join(l, ",")
+}
+
+type nameMap map[string]string
+
+func (m nameMap) Name() string {
+	return m["name"]
+}
+
+type nameLevel int
+
+func (l *nameLevel) Name() string {
+	return strconv.Itoa(int(*l))
+}
+
+func TestConfigureTypeKey(t *testing.T) {
+	c := New(WithTypeKey("kind"))
+	c.Register("D", func() *D {
+		return &D{}
+	})
+	c.LoadJSON([]byte(`{"kind": "D", "E1": "x"}`))
+	var object C
+	if err := c.Configure(&object); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	if d, ok := object.(*D); !ok || d.E1 != "x" {
+		t.Errorf("Configure() = %#v, expected a *D", object)
+	}
+	if err := c.Clone().Configure(&object); err != nil {
+		t.Errorf("Clone().Configure(): %v", err)
+	}
+
+	// the type key may be specified for a field and the values in it
+	c = New()
+	c.Register("D", func() *D {
+		return &D{}
+	})
+	c.LoadJSON([]byte(`{
+		"Plugins": [{"kind": "D", "E1": "a"}, {"kind": "D", "E1": "b"}],
+		"Main": {"type": "D", "E1": "c"}
+	}`))
+	var app struct {
+		Plugins []C `config:",typekey=kind"`
+		Main    C
+	}
+	if err := c.ConfigureStrict(&app); err != nil {
+		t.Fatalf("ConfigureStrict(): %v", err)
+	}
+	if len(app.Plugins) != 2 || app.Plugins[1].(*D).E1 != "b" || app.Main.(*D).E1 != "c" {
+		t.Errorf("ConfigureStrict() = %+v", app)
+	}
+	if err := c.Configure(&app); err != nil {
+		t.Errorf("Configure() with the configured interfaces: %v", err)
+	}
+	c.Set("Plugins.0", map[string]interface{}{"type": "D"})
+	app.Plugins = nil
+	err := c.Configure(&app)
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "Plugins.0" || e.Message != "missing the kind element" {
+		t.Errorf("Configure() returned %v, expected a ConfigValueError for %q", err, "Plugins.0")
+	}
+}
+
+func TestConfigureNonStructType(t *testing.T) {
+	c := New(WithTypeKey("kind"))
+	c.Register("list", func() nameList {
+		return nil
+	})
+	c.Register("map", func() nameMap {
+		return nameMap{"name": "default"}
+	})
+	c.Register("level", func() nameLevel {
+		return 1
+	})
+	tests := []struct {
+		json, expected string
+	}{
+		{`{"kind": "list", "value": ["a", "b"]}`, "a,b"},
+		{`{"kind": "map", "name": "x"}`, "x"},
+		{`{"kind": "map"}`, "default"},
+		{`{"kind": "level", "value": 3}`, "3"},
+		{`{"kind": "level"}`, "1"},
+	}
+	for _, test := range tests {
+		c.SetData()
+		c.LoadJSON([]byte(test.json))
+		var n namer
+		if err := c.ConfigureStrict(&n); err != nil {
+			t.Errorf("ConfigureStrict(%v): %v", test.json, err)
+		} else if n.Name() != test.expected {
+			t.Errorf("ConfigureStrict(%v) = %q, expected %q", test.json, n.Name(), test.expected)
+		}
+	}
+
+	c.SetData()
+	c.LoadJSON([]byte(`{"kind": "level", "value": 3, "extra": 1}`))
+	var n namer
+	err := c.ConfigureStrict(&n)
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "extra" {
+		t.Errorf("ConfigureStrict() returned %v, expected a ConfigValueError for %q", err, "extra")
+	}
+	if err := c.Configure(&n); err != nil || n.Name() != "3" {
+		t.Errorf("Configure() = %v, %v, expected the extra element to be ignored", n, err)
+	}
+}
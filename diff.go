@@ -0,0 +1,158 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ChangeKind describes how a configuration value differs between two configurations.
+type ChangeKind int
+
+const (
+	// ChangeAdded means the value only exists in the new configuration.
+	ChangeAdded ChangeKind = iota
+	// ChangeRemoved means the value only exists in the old configuration.
+	ChangeRemoved
+	// ChangeModified means the value exists in both configurations but is different.
+	ChangeModified
+)
+
+// String returns the name of the change kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change describes a difference between two configurations.
+type Change struct {
+	Path string      // path to the changed value, in the dotted format used by Get()
+	Kind ChangeKind  // how the value was changed
+	Old  interface{} // the value in the old configuration, nil if the value is added
+	New  interface{} // the value in the new configuration, nil if the value is removed
+}
+
+// String returns a human-readable description of the change.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("%v: added %v", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("%v: removed %v", c.Path, c.Old)
+	}
+	return fmt.Sprintf("%v: modified %v => %v", c.Path, c.Old, c.New)
+}
+
+// Diff returns the differences between the old configuration a and the new configuration b.
+//
+// Both configurations are walked recursively. Maps are compared key by key, and arrays are compared
+// element by element. A change is reported for every value that is added, removed, or modified,
+// using the same dotted path format as Get(). The changes are sorted by the map keys and array indexes
+// along their paths. An empty result means the two configurations are the same.
+func Diff(a, b *Config) []Change {
+	changes := []Change{}
+	v1, v2 := a.data, b.data
+	if !v1.IsValid() && v2.Kind() == reflect.Map {
+		v1 = reflect.ValueOf(map[string]interface{}{})
+	}
+	if !v2.IsValid() && v1.Kind() == reflect.Map {
+		v2 = reflect.ValueOf(map[string]interface{}{})
+	}
+	return diff(changes, "", v1, v2)
+}
+
+// diff appends the differences between the values v1 and v2 located at the given path.
+func diff(changes []Change, path string, v1, v2 reflect.Value) []Change {
+	if v1.Kind() == reflect.Map && v2.Kind() == reflect.Map {
+		return diffMaps(changes, path, v1, v2)
+	}
+	if isArray(v1) && isArray(v2) {
+		return diffArrays(changes, path, v1, v2)
+	}
+	if !v1.IsValid() && !v2.IsValid() {
+		return changes
+	}
+	if v1.IsValid() && v2.IsValid() && reflect.DeepEqual(v1.Interface(), v2.Interface()) {
+		return changes
+	}
+	return append(changes, Change{path, ChangeModified, valueInterface(v1), valueInterface(v2)})
+}
+
+func diffMaps(changes []Change, path string, v1, v2 reflect.Value) []Change {
+	keys1, keys2 := mapKeys(v1), mapKeys(v2)
+	names := make([]string, 0, len(keys1)+len(keys2))
+	for name := range keys1 {
+		names = append(names, name)
+	}
+	for name := range keys2 {
+		if _, ok := keys1[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := joinPath(path, name)
+		k1, ok1 := keys1[name]
+		k2, ok2 := keys2[name]
+		switch {
+		case !ok1:
+			changes = append(changes, Change{p, ChangeAdded, nil, valueInterface(mapIndex(v2, k2))})
+		case !ok2:
+			changes = append(changes, Change{p, ChangeRemoved, valueInterface(mapIndex(v1, k1)), nil})
+		default:
+			changes = diff(changes, p, mapIndex(v1, k1), mapIndex(v2, k2))
+		}
+	}
+	return changes
+}
+
+func diffArrays(changes []Change, path string, v1, v2 reflect.Value) []Change {
+	n1, n2 := v1.Len(), v2.Len()
+	for i := 0; i < n1 || i < n2; i++ {
+		p := joinPath(path, strconv.Itoa(i))
+		switch {
+		case i >= n1:
+			changes = append(changes, Change{p, ChangeAdded, nil, valueInterface(getElement(v2, strconv.Itoa(i)))})
+		case i >= n2:
+			changes = append(changes, Change{p, ChangeRemoved, valueInterface(getElement(v1, strconv.Itoa(i))), nil})
+		default:
+			changes = diff(changes, p, getElement(v1, strconv.Itoa(i)), getElement(v2, strconv.Itoa(i)))
+		}
+	}
+	return changes
+}
+
+// mapKeys returns the keys of a map indexed by their string representations.
+func mapKeys(v reflect.Value) map[string]reflect.Value {
+	keys := make(map[string]reflect.Value, v.Len())
+	for _, key := range v.MapKeys() {
+		keys[keyString(key)] = key
+	}
+	return keys
+}
+
+// isArray checks if a value is an array or slice.
+func isArray(v reflect.Value) bool {
+	return v.Kind() == reflect.Array || v.Kind() == reflect.Slice
+}
+
+// valueInterface returns the value as an interface{}, or nil if the value is invalid.
+func valueInterface(v reflect.Value) interface{} {
+	if v.IsValid() {
+		return v.Interface()
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		old, new string
+		expected string
+	}{
+		{`null`, `null`, ``},
+		{`{"a":1}`, `{"a":1}`, ``},
+		{`null`, `{"a":1}`, `a: added 1`},
+		{`{"a":1}`, `null`, `a: removed 1`},
+		{`1`, `2`, `: modified 1 => 2`},
+		{`{"a":1}`, `{"a":2}`, `a: modified 1 => 2`},
+		{`{"a":1}`, `{"a":null}`, `a: modified 1 => <nil>`},
+		{`{"a":1,"b":2}`, `{"b":2,"c":3}`, `a: removed 1|c: added 3`},
+		{`{"a":{"b":1,"c":{"d":2}}}`, `{"a":{"b":1,"c":{"d":3}}}`, `a.c.d: modified 2 => 3`},
+		{`{"a":{"b":1}}`, `{"a":[1]}`, `a: modified map[b:1] => [1]`},
+		{`{"a":[1,2,3]}`, `{"a":[1,4]}`, `a.1: modified 2 => 4|a.2: removed 3`},
+		{`{"a":[1]}`, `{"a":[1,{"b":2}]}`, `a.1: added map[b:2]`},
+		{`{"a":[{"b":1}]}`, `{"a":[{"b":2}]}`, `a.0.b: modified 1 => 2`},
+	}
+	for _, test := range tests {
+		a, b := New(), New()
+		a.LoadJSON([]byte(test.old))
+		b.LoadJSON([]byte(test.new))
+		changes := Diff(a, b)
+		s := make([]string, len(changes))
+		for i, change := range changes {
+			s[i] = change.String()
+		}
+		if result := strings.Join(s, "|"); result != test.expected {
+			t.Errorf("Diff(%v, %v) = %q, expected %q", test.old, test.new, result, test.expected)
+		}
+	}
+}
+
+func TestDiffWithYAML(t *testing.T) {
+	a, b := New(), New()
+	a.Load("testdata/c1.yaml")
+	b.Load("testdata/c1.yaml", "testdata/c2.yaml")
+	changes := Diff(a, b)
+	expected := []Change{
+		{"A2", ChangeModified, 2, 3},
+		{"A5", ChangeModified, nil, "a5"},
+		{"A6.B2.C2", ChangeAdded, nil, "c2"},
+	}
+	if fmt.Sprint(changes) != fmt.Sprint(expected) {
+		t.Errorf("Diff() = %v, expected %v", changes, expected)
+	}
+}
+
+func TestChangeKind(t *testing.T) {
+	tests := []struct {
+		kind     ChangeKind
+		expected string
+	}{
+		{ChangeAdded, "added"},
+		{ChangeRemoved, "removed"},
+		{ChangeModified, "modified"},
+		{ChangeKind(100), "unknown"},
+	}
+	for _, test := range tests {
+		if test.kind.String() != test.expected {
+			t.Errorf("ChangeKind(%d).String() = %q, expected %q", test.kind, test.kind.String(), test.expected)
+		}
+	}
+}
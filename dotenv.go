@@ -0,0 +1,319 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultDotEnvSeparator is the separator used to split the keys in .env files into paths
+// when the files are loaded by Load(). For example, the key "DB__HOST" corresponds to the path "DB.HOST".
+const DefaultDotEnvSeparator = "__"
+
+// LoadDotEnv loads configuration data from one or multiple .env files.
+//
+// Each line of a .env file is in the format of "KEY=value", which may be prefixed with "export".
+// Keys are split by the separator into paths, e.g. "DB__HOST=localhost" sets the value of the path "DB.HOST"
+// when the separator is "__". If the separator is empty, keys are not split. A key cannot be the prefix of
+// other keys, such as "DB" and "DB__HOST", and indexed keys such as "HOSTS__0" and "HOSTS__1" populate
+// an array if the indexes start from 0 without gaps.
+//
+// Values may be enclosed in single or double quotes, and quoted values may span multiple lines.
+// Single-quoted values are taken literally. In double-quoted and unquoted values, references
+// to variables in the format of "${VAR}", "$VAR", or "${VAR:-default}" are expanded with
+// the variables defined earlier in the same file or the environment variables. Double-quoted values may
+// also contain the escape sequences "\n", "\r", "\t", "\"", "\$", and "\\". Unquoted values that are integers,
+// floats, true, or false are converted into the corresponding types, and the rest are kept as strings.
+// Lines starting with "#" are comments, and so is the text following " #" in an unquoted value.
+//
+// Because keys in .env files are usually in upper case, you may want to create the configuration
+// with WithCaseInsensitiveKeys() so that the keys match struct field names in Configure().
+//
+// Files with the .env extension can also be loaded by Load(), which uses DefaultDotEnvSeparator.
+// The configuration data are merged according to the rules described in SetData().
+// Note that this method will NOT clear the existing configuration data.
+func (c *Config) LoadDotEnv(separator string, files ...string) error {
+	for _, file := range files {
+		if err := c.loadFile(file, singleDocument(parseDotEnv(separator))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalDotEnv parses the given .env data and populates it into the given variable,
+// splitting the keys into paths by DefaultDotEnvSeparator.
+func unmarshalDotEnv(bytes []byte, data interface{}) error {
+	return dotEnvUnmarshaler(DefaultDotEnvSeparator)(bytes, data)
+}
+
+// dotEnvUnmarshaler returns an UnmarshalFunc that parses .env data and splits the keys into paths by the separator.
+func dotEnvUnmarshaler(separator string) UnmarshalFunc {
+	parse := parseDotEnv(separator)
+	return func(bytes []byte, data interface{}) error {
+		root, _, err := parse(bytes)
+		if err != nil {
+			return err
+		}
+		return populate(data, root)
+	}
+}
+
+// parseDotEnv returns a function that parses .env data and splits the keys into paths by the separator.
+// The function returns the parsed value together with the positions of the values, indexed by their paths.
+func parseDotEnv(separator string) positionParser {
+	return func(bytes []byte) (interface{}, map[string]Position, error) {
+		root := map[string]interface{}{}
+		positions := map[string]Position{}
+		p := &dotEnvParser{s: string(bytes), line: 1, vars: map[string]string{}}
+		for {
+			key, value, err := p.next()
+			if err != nil {
+				return nil, nil, errorAtLine(p.line, "%v", err)
+			}
+			if key == "" {
+				break
+			}
+			keys := []string{key}
+			if separator != "" {
+				keys = strings.Split(key, separator)
+			}
+			if err := setDotEnvValue(root, keys, separator, value); err != nil {
+				return nil, nil, errorAtLine(p.line, "%v", err)
+			}
+			positions[PathOf(keys...)] = p.pos
+		}
+		indexArrays(root)
+		return root, positions, nil
+	}
+}
+
+// setDotEnvValue sets the value located by the keys split from a key by the separator in the given map.
+// An error is returned if the key is the prefix of another key or vice versa.
+func setDotEnvValue(m map[string]interface{}, keys []string, separator string, value interface{}) error {
+	key := strings.Join(keys, separator)
+	for i, k := range keys[:len(keys)-1] {
+		child, ok := m[k]
+		if !ok {
+			child = map[string]interface{}{}
+			m[k] = child
+		}
+		if m, ok = child.(map[string]interface{}); !ok {
+			return fmt.Errorf("the key %q cannot be used with the key %q", key, strings.Join(keys[:i+1], separator))
+		}
+	}
+	if _, ok := m[keys[len(keys)-1]].(map[string]interface{}); ok {
+		return fmt.Errorf("the key %q cannot be used as the prefix of other keys", key)
+	}
+	m[keys[len(keys)-1]] = value
+	return nil
+}
+
+// dotEnvParser parses .env data.
+type dotEnvParser struct {
+	s    string            // the data
+	i    int               // the current position
+	line int               // the current line number
+	pos  Position          // the position of the last parsed key
+	vars map[string]string // the variables parsed so far
+}
+
+// next parses the next variable. An empty key is returned when the end of the data is reached.
+func (p *dotEnvParser) next() (string, interface{}, error) {
+	if !p.skipBlank() {
+		return "", nil, nil
+	}
+	if strings.HasPrefix(p.s[p.i:], "export ") || strings.HasPrefix(p.s[p.i:], "export\t") {
+		p.i += len("export")
+		p.skipSpaces()
+	}
+	start := p.i
+	p.pos = Position{Line: p.line, Column: utf8.RuneCountInString(p.s[strings.LastIndexByte(p.s[:start], '\n')+1:start]) + 1}
+	for p.i < len(p.s) && isDotEnvKeyChar(p.s[p.i]) {
+		p.i++
+	}
+	key := p.s[start:p.i]
+	if key == "" {
+		return "", nil, fmt.Errorf("unexpected character %q", p.s[p.i])
+	}
+	p.skipSpaces()
+	if p.i == len(p.s) || p.s[p.i] != '=' {
+		return "", nil, fmt.Errorf("missing '=' after the key %q", key)
+	}
+	p.i++
+	p.skipSpaces()
+
+	var value interface{}
+	var err error
+	if p.i < len(p.s) && (p.s[p.i] == '"' || p.s[p.i] == '\'') {
+		var s string
+		if s, err = p.quotedValue(); err == nil {
+			p.vars[key] = s
+			value = s
+			err = p.lineEnd()
+		}
+	} else {
+		start := p.i
+		p.skipLine()
+		s := p.s[start:p.i]
+		if i := strings.Index(s, " #"); i >= 0 {
+			s = s[:i]
+		}
+		if i := strings.Index(s, "\t#"); i >= 0 {
+			s = s[:i]
+		}
+		if s, err = p.expand(strings.TrimSpace(s), false); err == nil {
+			p.vars[key] = s
+			value = scalarValue(s)
+		}
+	}
+	return key, value, err
+}
+
+// quotedValue parses a value enclosed in single or double quotes.
+func (p *dotEnvParser) quotedValue() (string, error) {
+	quote := p.s[p.i]
+	start, line := p.i+1, p.line
+	for p.i++; p.i < len(p.s) && p.s[p.i] != quote; p.i++ {
+		if p.s[p.i] == '\\' && quote == '"' && p.i+1 < len(p.s) {
+			p.i++
+		}
+		if p.s[p.i] == '\n' {
+			p.line++
+		}
+	}
+	if p.i == len(p.s) {
+		p.line = line
+		return "", errors.New("unterminated quoted value")
+	}
+	s := p.s[start:p.i]
+	p.i++
+	if quote == '\'' {
+		return s, nil
+	}
+	return p.expand(s, true)
+}
+
+// lineEnd checks that only spaces and a comment follow a quoted value on the same line.
+func (p *dotEnvParser) lineEnd() error {
+	p.skipSpaces()
+	if p.i < len(p.s) && p.s[p.i] != '\n' && p.s[p.i] != '\r' && p.s[p.i] != '#' {
+		start := p.i
+		p.skipLine()
+		return fmt.Errorf("unexpected %q after the quoted value", strings.TrimSpace(p.s[start:p.i]))
+	}
+	p.skipLine()
+	return nil
+}
+
+// expand expands the variable references in a value. If escapes is true, the escape sequences in the value
+// are also replaced.
+func (p *dotEnvParser) expand(s string, escapes bool) (string, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && escapes && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case '"', '$', '\\':
+				buf.WriteByte(s[i])
+			default:
+				buf.WriteByte('\\')
+				buf.WriteByte(s[i])
+			}
+		case c == '$' && i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference %q", s[i:])
+			}
+			name, def, hasDefault := s[i+2:i+end], "", false
+			if j := strings.Index(name, ":-"); j >= 0 {
+				name, def, hasDefault = name[:j], name[j+2:], true
+			}
+			value, ok := p.lookup(name)
+			if (!ok || value == "") && hasDefault {
+				var err error
+				if value, err = p.expand(def, false); err != nil {
+					return "", err
+				}
+			}
+			buf.WriteString(value)
+			i += end
+		case c == '$' && i+1 < len(s) && isVarStart(s[i+1]):
+			j := i + 1
+			for j < len(s) && (isVarStart(s[j]) || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			value, _ := p.lookup(s[i+1 : j])
+			buf.WriteString(value)
+			i = j - 1
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), nil
+}
+
+// lookup returns the value of a variable defined earlier in the data or in the environment.
+func (p *dotEnvParser) lookup(name string) (string, bool) {
+	if value, ok := p.vars[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+// skipBlank skips blank lines and comments. It returns false if the end of the data is reached.
+func (p *dotEnvParser) skipBlank() bool {
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case '\n':
+			p.line++
+			p.i++
+		case ' ', '\t', '\r':
+			p.i++
+		case '#':
+			p.skipLine()
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// skipSpaces skips spaces and tabs.
+func (p *dotEnvParser) skipSpaces() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// skipLine skips the rest of the current line, excluding the line break.
+func (p *dotEnvParser) skipLine() {
+	for p.i < len(p.s) && p.s[p.i] != '\n' {
+		p.i++
+	}
+}
+
+// isDotEnvKeyChar checks if a character can be used in a .env key.
+func isDotEnvKeyChar(c byte) bool {
+	return isVarStart(c) || c >= '0' && c <= '9' || c == '.' || c == '-'
+}
+
+// isVarStart checks if a character can start a variable name.
+func isVarStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestUnmarshalDotEnv(t *testing.T) {
+	os.Setenv("CONFIG_TEST_HOME", "/home/test")
+	defer os.Unsetenv("CONFIG_TEST_HOME")
+
+	tests := []struct {
+		env      string
+		expected string
+	}{
+		{"", `{}`},
+		{"A=1\nB = x \nC=\n", `{"A":1,"B":"x","C":""}`},
+		{"# comment\n\n  # comment\nA=1 # comment\nB=x#y", `{"A":1,"B":"x#y"}`},
+		{"export A=1\n  export\tB=2\nexporter=3", `{"A":1,"B":2,"exporter":3}`},
+		{"DB__HOST=localhost\nDB__PORT=3306\nDB__OPTIONS__SSL=true", `{"DB":{"HOST":"localhost","OPTIONS":{"SSL":true},"PORT":3306}}`},
+		{`A="x # y" # comment` + "\n" + `B='x\ny $A'` + "\n" + `C="1\t\"2\"\n\$A\\"`, `{"A":"x # y","B":"x\\ny $A","C":"1\t\"2\"\n$A\\"}`},
+		{"A=\"line1\nline2\"\nB='line3\nline4'\nC=1", `{"A":"line1\nline2","B":"line3\nline4","C":1}`},
+		{"A=\"1\"\nB=1.50\nC=0123", `{"A":"1","B":"1.50","C":"0123"}`},
+		{"A=x\nB=${A}y\nC=\"$A-${B}\"\nD=${CONFIG_TEST_HOME}/app\nE='${A}'", `{"A":"x","B":"xy","C":"x-xy","D":"/home/test/app","E":"${A}"}`},
+		{"A=${UNDEFINED_CONFIG_VAR:-default}\nB=${A:-x}\nC=$UNDEFINED_CONFIG_VAR\nD=$\nE=a$1", `{"A":"default","B":"default","C":"","D":"$","E":"a$1"}`},
+		{"A=C:\\dir\\file", `{"A":"C:\\dir\\file"}`},
+		{"A=1\r\nB=\"2\"\r\n", `{"A":1,"B":"2"}`},
+		{"HOSTS__1=y\nHOSTS__0=x\nDB__0__HOST=z\nPORTS__1=1\nPORTS__2=2", `{"DB":[{"HOST":"z"}],"HOSTS":["x","y"],"PORTS":{"1":1,"2":2}}`},
+	}
+	for _, test := range tests {
+		var data interface{}
+		if err := dotEnvUnmarshaler(DefaultDotEnvSeparator)([]byte(test.env), &data); err != nil {
+			t.Errorf("unmarshal(%q): %v", test.env, err)
+			continue
+		}
+		if s, _ := json.Marshal(data); string(s) != test.expected {
+			t.Errorf("unmarshal(%q) = %v, expected %v", test.env, string(s), test.expected)
+		}
+	}
+
+	var data interface{}
+	if err := dotEnvUnmarshaler("")([]byte("DB__HOST=x\nDB_PORT=1"), &data); err != nil {
+		t.Errorf("unmarshal() without separator: %v", err)
+	} else if s, _ := json.Marshal(data); string(s) != `{"DB_PORT":1,"DB__HOST":"x"}` {
+		t.Errorf("unmarshal() without separator = %v", string(s))
+	}
+	if err := dotEnvUnmarshaler("_")([]byte("DB_HOST=x\nDB_PORT=1"), &data); err != nil {
+		t.Errorf("unmarshal() with separator _: %v", err)
+	} else if s, _ := json.Marshal(data); string(s) != `{"DB":{"HOST":"x","PORT":1}}` {
+		t.Errorf("unmarshal() with separator _ = %v", string(s))
+	}
+	if err := dotEnvUnmarshaler("_")([]byte("LOG=info\nLOG_FILE=app.log"), &data); err == nil || err.Error() != `2: the key "LOG_FILE" cannot be used with the key "LOG"` {
+		t.Errorf("unmarshal() with separator _ error = %v", err)
+	}
+
+	errors := []struct {
+		env      string
+		expected string
+	}{
+		{"A", `1: missing '=' after the key "A"`},
+		{"\n\nA B=1", `3: missing '=' after the key "A"`},
+		{"=1", `1: unexpected character '='`},
+		{"A=\"x\n\ny", "1: unterminated quoted value"},
+		{"A='x' y", `1: unexpected "y" after the quoted value`},
+		{"A=${B", `1: unterminated variable reference "${B"`},
+		{"DB=x\nDB__HOST=y", `2: the key "DB__HOST" cannot be used with the key "DB"`},
+		{"DB__HOST=y\nDB=x", `2: the key "DB" cannot be used as the prefix of other keys`},
+	}
+	for _, test := range errors {
+		var data interface{}
+		if err := dotEnvUnmarshaler(DefaultDotEnvSeparator)([]byte(test.env), &data); err == nil || err.Error() != test.expected {
+			t.Errorf("unmarshal(%q) error = %v, expected %v", test.env, err, test.expected)
+		}
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	c := New(WithCaseInsensitiveKeys())
+	c.LoadJSON([]byte(`{"Db": {"Host": "localhost", "Port": 3306}}`))
+	if err := c.LoadDotEnv("_", "testdata/c3.env"); err != nil {
+		t.Fatalf("LoadDotEnv(): %v", err)
+	}
+	var db struct {
+		Host string
+		Port int
+		Name string
+	}
+	if err := c.Configure(&db, "DB"); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+	if db.Host != "db.example.com" || db.Port != 3306 || db.Name != "app" {
+		t.Errorf("Configure() = %+v", db)
+	}
+	if s := c.Source("DB.Host"); s != "testdata/c3.env" {
+		t.Errorf(`Source("DB.Host") = %q, expected %q`, s, "testdata/c3.env")
+	}
+	if err := c.LoadDotEnv("_", "testdata/none.env"); err == nil {
+		t.Errorf("LoadDotEnv() with a missing file: expected an error, got nil")
+	}
+	if err := New().LoadDotEnv("__", "testdata/c1.yaml"); err == nil {
+		t.Errorf("LoadDotEnv() with a malformed file: expected an error, got nil")
+	}
+}
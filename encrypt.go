@@ -0,0 +1,385 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// encryptedPrefix is the prefix of an encrypted value.
+const encryptedPrefix = "ENC[AES256_GCM,"
+
+// EncryptionKeySize is the size in bytes of the keys used to encrypt and decrypt configuration values.
+const EncryptionKeySize = 32
+
+// marshalFuncMap maps configuration file extensions to the corresponding marshal functions.
+var marshalFuncMap = map[string]func(interface{}) ([]byte, error){
+	".yaml":  yaml.Marshal,
+	".yml":   yaml.Marshal,
+	".json":  marshalJSON,
+	".json5": marshalJSON,
+	".toml": func(data interface{}) ([]byte, error) {
+		var buf bytes.Buffer
+		err := toml.NewEncoder(&buf).Encode(data)
+		return buf.Bytes(), err
+	},
+}
+
+// marshalJSON encodes the data in indented JSON format.
+func marshalJSON(data interface{}) ([]byte, error) {
+	bytes, err := json.MarshalIndent(data, "", "  ")
+	return append(bytes, '\n'), err
+}
+
+// WithEncryptionKey sets the key used to decrypt the encrypted configuration values.
+//
+// Encrypted values are strings in the format of "ENC[AES256_GCM,data:...,iv:...,tag:...,type:...]",
+// which are produced by Encrypt(). They are decrypted when the configuration is loaded by Load()
+// or LoadJSON(). The key must be EncryptionKeySize bytes long. You may use ReadEncryptionKey() or
+// EncryptionKeyFromEnv() to obtain the key.
+func WithEncryptionKey(key []byte) Option {
+	return func(c *Config) {
+		c.key = key
+	}
+}
+
+// GenerateEncryptionKey generates a random key that can be used to encrypt configuration values.
+// The key is returned in base64 encoding, which can be saved in a file or an environment variable.
+func GenerateEncryptionKey() (string, error) {
+	key := make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseEncryptionKey decodes a base64-encoded encryption key.
+func ParseEncryptionKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %v", err)
+	}
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("invalid encryption key: expected %v bytes, got %v", EncryptionKeySize, len(key))
+	}
+	return key, nil
+}
+
+// ReadEncryptionKey reads a base64-encoded encryption key from the given file.
+func ReadEncryptionKey(file string) ([]byte, error) {
+	bytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEncryptionKey(string(bytes))
+}
+
+// EncryptionKeyFromEnv reads a base64-encoded encryption key from the given environment variable.
+func EncryptionKeyFromEnv(name string) ([]byte, error) {
+	s, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %v is not set", name)
+	}
+	return ParseEncryptionKey(s)
+}
+
+// Encrypt rewrites a configuration file with the values at the specified paths encrypted by the key.
+//
+// The paths may contain wildcards and filters as described in Query(), such as "**.Password".
+// If a path refers to a map or array, all values inside it will be encrypted. Values that are
+// already encrypted are kept as they are. Each value is encrypted together with its path, so that
+// an encrypted value cannot be moved to a different path.
+//
+// The file format is determined by the file name extension (.json, .json5, .yaml, .yml, .toml).
+// The documents in a multi-document YAML file are encrypted separately.
+// The file is replaced by renaming a temporary file written in the same directory, so it is left unchanged
+// if an error occurs. Note that comments in the file are not preserved.
+func Encrypt(file string, key []byte, paths ...string) error {
+	marshal, ok := marshalFuncMap[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		return FileTypeError(file)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	docs, _, err := readFile(file, parserOf(UnmarshalFuncMap[strings.ToLower(filepath.Ext(file))]))
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	for i, doc := range docs {
+		if doc, err = encryptDocument(doc, key, paths); err != nil {
+			return err
+		}
+		bytes, err := marshal(doc)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(bytes)
+	}
+	return writeFile(file, out.Bytes(), info.Mode())
+}
+
+// writeFile replaces the content of a file with the data. The data are written to a temporary file in the same
+// directory, which is then renamed to the file, so that the file is left unchanged if the writing fails.
+func writeFile(file string, data []byte, mode os.FileMode) error {
+	file, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(mode.Perm()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
+
+// encryptDocument encrypts the values at the specified paths in the configuration data.
+func encryptDocument(data interface{}, key []byte, paths []string) (interface{}, error) {
+	c := New()
+	c.data = reflect.ValueOf(data)
+	for _, path := range paths {
+		results, err := c.Query(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			v, err := encryptValues(reflect.ValueOf(result.Value), key, result.Path)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.Set(result.Path, valueInterface(v)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c.Data(), nil
+}
+
+// encryptValues returns the value with all scalars in it encrypted.
+func encryptValues(v reflect.Value, key []byte, path string) (reflect.Value, error) {
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return v, nil
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, k := range v.MapKeys() {
+			e, err := encryptValues(v.MapIndex(k), key, joinPath(path, keyString(k)))
+			if err != nil {
+				return v, err
+			}
+			if !e.IsValid() {
+				e = reflect.Zero(v.Type().Elem())
+			}
+			m.SetMapIndex(k, e)
+		}
+		return m, nil
+	case reflect.Array, reflect.Slice:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			e, err := encryptValues(v.Index(i), key, joinPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return v, err
+			}
+			s[i] = valueInterface(e)
+		}
+		return reflect.ValueOf(s), nil
+	}
+
+	var typ string
+	switch v.Kind() {
+	case reflect.String:
+		if strings.HasPrefix(v.String(), encryptedPrefix) {
+			return v, nil
+		}
+		typ = "str"
+	case reflect.Bool:
+		typ = "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		typ = "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		typ = "uint"
+	case reflect.Float32, reflect.Float64:
+		typ = "float"
+	default:
+		return v, &ConfigValueError{Path: path, Message: fmt.Sprintf("%v cannot be encrypted", v.Type())}
+	}
+	s, err := encryptString(fmt.Sprint(v.Interface()), typ, key, path)
+	if err != nil {
+		return v, &ConfigValueError{Path: path, Message: err.Error()}
+	}
+	return reflect.ValueOf(s), nil
+}
+
+// encryptString encrypts a string using the key and returns the encrypted value in the format of
+// "ENC[AES256_GCM,data:...,iv:...,tag:...,type:...]". The path is used as the additional authenticated data.
+func encryptString(s, typ string, key []byte, path string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, []byte(s), []byte(path))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	encode := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("%vdata:%v,iv:%v,tag:%v,type:%v]", encryptedPrefix, encode(data), encode(iv), encode(tag), typ), nil
+}
+
+// decryptString decrypts an encrypted value produced by encryptString. Integers are decrypted as int values,
+// except unsigned integers too large for int, which are decrypted as uint64 values.
+func decryptString(s string, key []byte, path string) (interface{}, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, errors.New("malformed encrypted value")
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Split(s[len(encryptedPrefix):len(s)-1], ",") {
+		if i := strings.IndexByte(field, ':'); i > 0 {
+			fields[field[:i]] = field[i+1:]
+		}
+	}
+	var data, iv, tag []byte
+	for name, p := range map[string]*[]byte{"data": &data, "iv": &iv, "tag": &tag} {
+		var err error
+		if *p, err = base64.StdEncoding.DecodeString(fields[name]); err != nil {
+			return nil, fmt.Errorf("malformed encrypted value: invalid %v", name)
+		}
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != gcm.NonceSize() {
+		return nil, errors.New("malformed encrypted value: invalid iv")
+	}
+	plain, err := gcm.Open(nil, iv, append(data, tag...), []byte(path))
+	if err != nil {
+		return nil, errors.New("unable to decrypt the value: wrong key or path")
+	}
+
+	value := string(plain)
+	switch fields["type"] {
+	case "int":
+		n, err := strconv.ParseInt(value, 10, strconv.IntSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int value: %v", err)
+		}
+		return int(n), nil
+	case "uint":
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uint value: %v", err)
+		}
+		if i := int(n); i >= 0 && uint64(i) == n {
+			return i, nil
+		}
+		return n, nil
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	}
+	return value, nil
+}
+
+// newGCM creates an AES-256-GCM cipher with the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("invalid encryption key: expected %v bytes, got %v", EncryptionKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptValues decrypts all encrypted values in the loaded configuration data in place.
+func (c *Config) decryptValues(v reflect.Value, path string) (reflect.Value, error) {
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if !strings.HasPrefix(v.String(), encryptedPrefix) {
+			return v, nil
+		}
+		if c.key == nil {
+			return v, &ConfigValueError{Path: path, Message: "an encryption key is required to decrypt the value"}
+		}
+		d, err := decryptString(v.String(), c.key, path)
+		if err != nil {
+			return v, &ConfigValueError{Path: path, Message: err.Error()}
+		}
+		// the decrypted values are masked by Redacted() and String()
+		c.mu.Lock()
+		c.secretPaths[c.sourceKey(path)] = true
+		c.mu.Unlock()
+		return reflect.ValueOf(d), nil
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e, err := c.decryptValues(v.MapIndex(k), joinPath(path, keyString(k)))
+			if err != nil {
+				return v, err
+			}
+			if e.IsValid() && e.Type().AssignableTo(v.Type().Elem()) {
+				v.SetMapIndex(k, e)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			e, err := c.decryptValues(v.Index(i), joinPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return v, err
+			}
+			if e.IsValid() && e.Type().AssignableTo(v.Type().Elem()) {
+				v.Index(i).Set(e)
+			}
+		}
+	}
+	return v, nil
+}
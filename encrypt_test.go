@@ -0,0 +1,220 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncryptionKey(t *testing.T) {
+	s, err := GenerateEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseEncryptionKey(s + "\n")
+	if err != nil || len(key) != EncryptionKeySize {
+		t.Errorf("ParseEncryptionKey(%q) = %v, %v", s, key, err)
+	}
+
+	os.Setenv("OZZO_CONFIG_TEST_KEY", s)
+	defer os.Unsetenv("OZZO_CONFIG_TEST_KEY")
+	if key2, err := EncryptionKeyFromEnv("OZZO_CONFIG_TEST_KEY"); err != nil || string(key2) != string(key) {
+		t.Errorf("EncryptionKeyFromEnv() = %v, %v, expected %v", key2, err, key)
+	}
+	if _, err := EncryptionKeyFromEnv("OZZO_CONFIG_TEST_MISSING"); err == nil {
+		t.Errorf("EncryptionKeyFromEnv() expected an error, got nil")
+	}
+
+	dir, _ := ioutil.TempDir("", "ozzo-config")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "key")
+	ioutil.WriteFile(file, []byte(s), 0600)
+	if key2, err := ReadEncryptionKey(file); err != nil || string(key2) != string(key) {
+		t.Errorf("ReadEncryptionKey() = %v, %v, expected %v", key2, err, key)
+	}
+	if _, err := ReadEncryptionKey(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("ReadEncryptionKey() expected an error, got nil")
+	}
+
+	for _, s := range []string{"abc", "YWJj"} {
+		if _, err := ParseEncryptionKey(s); err == nil {
+			t.Errorf("ParseEncryptionKey(%q) expected an error, got nil", s)
+		}
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	s, _ := GenerateEncryptionKey()
+	key, _ := ParseEncryptionKey(s)
+	dir, _ := ioutil.TempDir("", "ozzo-config")
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"c1.json", "c1.yaml", "c1.toml"} {
+		bytes, _ := ioutil.ReadFile(filepath.Join("testdata", name))
+		file := filepath.Join(dir, name)
+		ioutil.WriteFile(file, bytes, 0600)
+
+		if err := Encrypt(file, key, "A1", "A2", "A6.**", "A7.1"); err != nil {
+			t.Errorf("Encrypt(%q): %v", name, err)
+			continue
+		}
+		// encrypting again keeps the encrypted values
+		if err := Encrypt(file, key, "A1"); err != nil {
+			t.Errorf("Encrypt(%q): %v", name, err)
+			continue
+		}
+		var data interface{}
+		load(file, &data)
+		raw := New()
+		raw.SetData(data)
+		for _, path := range []string{"A1", "A2", "A6.B1", "A6.B2.C1", "A7.1"} {
+			if v := raw.GetString(path); !strings.HasPrefix(v, encryptedPrefix) {
+				t.Errorf("Encrypt(%q) did not encrypt %q: %v", name, path, v)
+			}
+		}
+		if v := raw.Get("A7.0"); v != "d1" {
+			t.Errorf("Encrypt(%q) changed %q to %v", name, "A7.0", v)
+		}
+
+		c := New(WithEncryptionKey(key))
+		if err := c.Load(file); err != nil {
+			t.Errorf("Load(%q): %v", name, err)
+			continue
+		}
+		tests := []struct {
+			path     string
+			expected interface{}
+		}{
+			{"A1", "a1"},
+			{"A3", true},
+			{"A6.B1", "b1"},
+			{"A6.B2.C1", "c1"},
+			{"A7.0", "d1"},
+			{"A7.1", "d2"},
+		}
+		for _, test := range tests {
+			if v := c.Get(test.path); v != test.expected {
+				t.Errorf("Load(%q), Get(%q) = %v, expected %v", name, test.path, v, test.expected)
+			}
+		}
+		if v := c.GetInt("A2"); v != 2 {
+			t.Errorf("Load(%q), GetInt(%q) = %v, expected %v", name, "A2", v, 2)
+		}
+
+		if err := New().Load(file); err == nil {
+			t.Errorf("Load(%q) without a key expected an error, got nil", name)
+		}
+		s2, _ := GenerateEncryptionKey()
+		key2, _ := ParseEncryptionKey(s2)
+		if err := New(WithEncryptionKey(key2)).Load(file); err == nil {
+			t.Errorf("Load(%q) with a wrong key expected an error, got nil", name)
+		}
+	}
+
+	// the documents in a multi-document YAML file are encrypted separately
+	bytes, _ := ioutil.ReadFile("testdata/c5.yaml")
+	file := filepath.Join(dir, "c5.yaml")
+	ioutil.WriteFile(file, bytes, 0600)
+	if err := Encrypt(file, key, "DB.Port"); err != nil {
+		t.Errorf("Encrypt(%q): %v", "c5.yaml", err)
+	}
+	bytes, _ = ioutil.ReadFile(file)
+	if n := strings.Count(string(bytes), encryptedPrefix); n != 2 {
+		t.Errorf("Encrypt(%q) encrypted %v values, expected %v", "c5.yaml", n, 2)
+	}
+	c := New(WithEncryptionKey(key))
+	if err := c.Load(file); err != nil {
+		t.Errorf("Load(%q): %v", "c5.yaml", err)
+	} else if v := c.Get("DB.Port"); v != 3307 {
+		t.Errorf("Load(%q), Get(%q) = %v, expected %v", "c5.yaml", "DB.Port", v, 3307)
+	}
+
+	// the file is replaced with the same mode, without leaving temporary files
+	file = filepath.Join(dir, "mode.json")
+	ioutil.WriteFile(file, []byte(`{"A": "a"}`), 0640)
+	if err := Encrypt(file, key, "A"); err != nil {
+		t.Errorf("Encrypt(%q): %v", "mode.json", err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("Encrypt(%q) changed the file mode: %v, %v", "mode.json", info, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, ".mode.json.*")); len(files) > 0 {
+		t.Errorf("Encrypt(%q) left temporary files: %v", "mode.json", files)
+	}
+
+	if err := Encrypt(filepath.Join(dir, "missing.json"), key, "A1"); err == nil {
+		t.Errorf("Encrypt() expected an error for a missing file, got nil")
+	}
+	if err := Encrypt(filepath.Join(dir, "c1.xyz"), key, "A1"); err == nil {
+		t.Errorf("Encrypt() expected an error for an unknown file type, got nil")
+	}
+}
+
+func TestDecryptValues(t *testing.T) {
+	s, _ := GenerateEncryptionKey()
+	key, _ := ParseEncryptionKey(s)
+	v1, _ := encryptString("abc", "str", key, "A.B")
+	v2, _ := encryptString("1.5", "float", key, "A.C.0")
+
+	c := New(WithEncryptionKey(key))
+	if err := c.LoadJSON([]byte(`{"A": {"B": "` + v1 + `", "C": ["` + v2 + `"]}}`)); err != nil {
+		t.Errorf("LoadJSON(): %v", err)
+	}
+	if v := c.Get("A.B"); v != "abc" {
+		t.Errorf(`Get("A.B") = %v, expected %v`, v, "abc")
+	}
+	if v := c.Get("A.C.0"); v != 1.5 {
+		t.Errorf(`Get("A.C.0") = %v, expected %v`, v, 1.5)
+	}
+	if v := c.Clone().Get("A.B"); v != "abc" {
+		t.Errorf(`Clone().Get("A.B") = %v, expected %v`, v, "abc")
+	}
+	// the decrypted values are masked
+	if s := c.String(); s != `{"A":{"B":"******","C":["******"]}}` {
+		t.Errorf("String() = %v, expected the decrypted values to be masked", s)
+	}
+
+	// the integers are decrypted with overflow checks
+	tests := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{int64(-5), -5},
+		{uint8(5), 5},
+		{uint64(18446744073709551615), uint64(18446744073709551615)},
+	}
+	for _, test := range tests {
+		e, err := encryptValues(reflect.ValueOf(test.value), key, "N")
+		if err != nil {
+			t.Errorf("encryptValues(%v): %v", test.value, err)
+			continue
+		}
+		if v, err := decryptString(e.String(), key, "N"); err != nil || v != test.expected {
+			t.Errorf("decryptString(%v) = %#v, %v, expected %#v", test.value, v, err, test.expected)
+		}
+	}
+	for _, typ := range []string{"int", "uint"} {
+		e, _ := encryptString("99999999999999999999", typ, key, "N")
+		if _, err := decryptString(e, key, "N"); err == nil {
+			t.Errorf("decryptString() with an overflowing %v: expected an error, got nil", typ)
+		}
+	}
+
+	// the value is bound to its path
+	err := c.LoadJSON([]byte(`{"X": "` + v1 + `"}`))
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "X" {
+		t.Errorf("LoadJSON() returned %v, expected a ConfigValueError for %q", err, "X")
+	}
+	for _, s := range []string{encryptedPrefix + "data:abc", encryptedPrefix + "data:!,iv:,tag:]", encryptedPrefix + "data:,iv:,tag:]"} {
+		if err := c.LoadJSON([]byte(`{"X": "` + s + `"}`)); err == nil {
+			t.Errorf("LoadJSON(%q) expected an error, got nil", s)
+		}
+	}
+}
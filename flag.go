@@ -0,0 +1,333 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// BindFlags sets the configuration values with the command-line flags that are explicitly set.
+//
+// The mapping maps flag names to configuration paths, e.g. {"db-host": "DB.Host"}. Only the flags
+// that are set on the command line override the corresponding configuration values, so that the values
+// loaded from files are kept for the flags that are not set. The flag set must be parsed before calling
+// this method, and the method is usually called after the configuration files are loaded.
+//
+// If a flag implements flag.Getter, the value returned by its Get() method is used, which keeps
+// the type of the flag (e.g. int or bool). Otherwise, the string representation of the flag value is used.
+// The source of the values set by this method is "BindFlags".
+func (c *Config) BindFlags(fs *flag.FlagSet, mapping map[string]string) error {
+	for name := range mapping {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("flag -%v is not defined", name)
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		path, ok := mapping[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		var value interface{} = f.Value.String()
+		if getter, ok := f.Value.(flag.Getter); ok {
+			value = getter.Get()
+		}
+		if err = c.Set(path, value); err == nil {
+			parts, _ := parsePath(path)
+			c.recordSources(PathOf(parts...), reflect.ValueOf(value), "BindFlags")
+		}
+	})
+	return err
+}
+
+// DefineFlags defines a command-line flag in the flag set for every field of the struct that can be configured
+// by Configure(), and returns the mapping from the flag names to the configuration paths, which can be passed
+// to BindFlags() after the flags are parsed.
+//
+// The struct fields are mapped to the configuration paths using the same rules as Configure(), and nested structs
+// are traversed recursively. The flag names are the paths in lower case with the keys separated by hyphens,
+// e.g. the field "DB.MaxConns" is bound to the flag "-db-max-conns". If a path is given, it is used as the prefix
+// of the configuration paths, as when it is passed to Configure().
+//
+// Flags are defined for fields of the string, bool, integer, float, and time.Duration types only.
+// The default values of the flags are taken from the configuration if available, or from the struct fields otherwise.
+// The defaults are left empty for secret references, encrypted values, and the fields with the "secret" option.
+// An error is returned without defining any flag if a flag name is already defined in the flag set or is shared
+// by multiple fields, or if a number in the configuration does not fit in the type of a field, such as 1.5 or 300
+// for a uint8 field.
+// The usage message of a flag may be specified using the "usage" tag of the field, e.g. `usage:"the database host"`.
+func (c *Config) DefineFlags(fs *flag.FlagSet, v interface{}, path ...string) (map[string]string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, &ConfigTargetError{rv}
+	}
+	t := rv.Elem().Type()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable to define flags for %v", t)
+	}
+
+	p := ""
+	if len(path) > 0 {
+		parts, err := parsePath(path[0])
+		if err != nil {
+			return nil, &ConfigPathError{Path: path[0], Message: err.Error()}
+		}
+		p = PathOf(parts...)
+	}
+	var defs []flagDefinition
+	if err := c.collectFlags(rv.Elem(), p, &defs); err != nil {
+		return nil, err
+	}
+
+	// check the names before defining any flag, as a FlagSet panics when a flag is redefined
+	mapping := map[string]string{}
+	for _, def := range defs {
+		if fs.Lookup(def.name) != nil {
+			return nil, fmt.Errorf("flag -%v for %q is already defined", def.name, def.path)
+		}
+		if path, ok := mapping[def.name]; ok {
+			return nil, fmt.Errorf("flag -%v is used for both %q and %q", def.name, path, def.path)
+		}
+		mapping[def.name] = def.path
+	}
+	for _, def := range defs {
+		def.define(fs)
+	}
+	return mapping, nil
+}
+
+// durationType is the type of time.Duration.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// flagDefinition describes a flag to be defined for a struct field.
+type flagDefinition struct {
+	name, path, usage string
+	value             reflect.Value // the default value of the flag
+}
+
+// define defines the flag in the flag set.
+func (def flagDefinition) define(fs *flag.FlagSet) {
+	v := def.value
+	switch {
+	case v.Type() == durationType:
+		fs.Duration(def.name, time.Duration(v.Int()), def.usage)
+	case v.Kind() == reflect.String:
+		fs.String(def.name, v.String(), def.usage)
+	case v.Kind() == reflect.Bool:
+		fs.Bool(def.name, v.Bool(), def.usage)
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		fs.Int64(def.name, v.Int(), def.usage)
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64:
+		fs.Uint64(def.name, v.Uint(), def.usage)
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		fs.Float64(def.name, v.Float(), def.usage)
+	}
+}
+
+// isFlagType checks if a flag can be defined for a field of the given type.
+func isFlagType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// collectFlags collects the flags for the fields of a struct located at the given path.
+// An error is returned if a number in the configuration cannot be the default value of a flag.
+// The fields promoted from embedded structs are located in the map of the struct.
+func (c *Config) collectFlags(v reflect.Value, path string, defs *[]flagDefinition) error {
+	for _, field := range c.configurableFields(v.Type()) {
+		if field.PkgPath != "" {
+			continue
+		}
+		options := parseTag(field)
+		p := joinPath(path, field.Name)
+
+		fv := fieldByIndex(v, field.Index, false)
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+			if fv.IsNil() {
+				fv = reflect.Zero(ft)
+			} else {
+				fv = fv.Elem()
+			}
+		}
+		if ft.Kind() == reflect.Struct {
+			if err := c.collectFlags(fv, p, defs); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isFlagType(ft) {
+			continue
+		}
+
+		usage := field.Tag.Get("usage")
+		if usage == "" {
+			usage = "sets " + p
+		}
+		value := reflect.New(ft).Elem()
+		value.Set(fv)
+		// use the raw configuration value, so that the secrets are never resolved and shown as the defaults
+		cv, _ := c.value(p)
+		for cv.Kind() == reflect.Interface {
+			cv = cv.Elem()
+		}
+		if _, secret := options["secret"]; secret || cv.Kind() == reflect.String &&
+			(strings.HasPrefix(cv.String(), secretPrefix) || strings.HasPrefix(cv.String(), encryptedPrefix)) {
+			value.Set(reflect.Zero(ft))
+		} else if cv.IsValid() {
+			if err := setFlagDefault(value, cv); err != nil {
+				return c.valueError(p, err.Error())
+			}
+		}
+		*defs = append(*defs, flagDefinition{flagNameOf(p), p, usage, value})
+	}
+	return nil
+}
+
+// setFlagDefault sets the default value of a flag with the configuration value if it can be converted.
+// Numbers are converted into the numeric types only, and strings are parsed for the other types.
+// An error is returned if a number does not fit in the numeric type of the flag, or if it is not
+// an integer while the type is an integer type.
+func setFlagDefault(value, cv reflect.Value) error {
+	_, isNumber := numberValue(cv)
+	if value.Kind() == reflect.String {
+		switch {
+		case cv.Kind() == reflect.String:
+			value.SetString(cv.String())
+		case isNumber || cv.Kind() == reflect.Bool:
+			value.SetString(fmt.Sprint(cv.Interface()))
+		}
+		return nil
+	}
+	if _, ok := numberValue(value); ok && isNumber {
+		if !setNumber(value, cv) {
+			return fmt.Errorf("%v cannot be converted into %v", cv.Interface(), value.Type())
+		}
+		return nil
+	}
+	if cv.Kind() == reflect.Bool && value.Kind() == reflect.Bool {
+		value.SetBool(cv.Bool())
+		return nil
+	}
+	if cv.Kind() != reflect.String {
+		return nil
+	}
+	s := cv.String()
+	switch kind := value.Kind(); {
+	case value.Type() == durationType:
+		if d, err := time.ParseDuration(s); err == nil {
+			value.SetInt(int64(d))
+		}
+	case kind == reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			value.SetBool(b)
+		}
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		if i, err := strconv.ParseInt(s, 10, value.Type().Bits()); err == nil {
+			value.SetInt(i)
+		}
+	case kind >= reflect.Uint && kind <= reflect.Uint64:
+		if u, err := strconv.ParseUint(s, 10, value.Type().Bits()); err == nil {
+			value.SetUint(u)
+		}
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		if f, err := strconv.ParseFloat(s, value.Type().Bits()); err == nil {
+			value.SetFloat(f)
+		}
+	}
+	return nil
+}
+
+// setNumber sets the numeric value with the number n. It returns false without setting the value if the number
+// does not fit in the type of the value, or if the number is not an integer while the type is an integer type.
+func setNumber(value, n reflect.Value) bool {
+	switch kind := value.Kind(); {
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		var i int64
+		switch n.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = n.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if n.Uint() > math.MaxInt64 {
+				return false
+			}
+			i = int64(n.Uint())
+		default:
+			f := n.Float()
+			if f != math.Trunc(f) || f < -(1<<63) || f >= 1<<63 {
+				return false
+			}
+			i = int64(f)
+		}
+		if value.OverflowInt(i) {
+			return false
+		}
+		value.SetInt(i)
+	case kind >= reflect.Uint && kind <= reflect.Uint64:
+		var u uint64
+		switch n.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n.Int() < 0 {
+				return false
+			}
+			u = uint64(n.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u = n.Uint()
+		default:
+			f := n.Float()
+			if f != math.Trunc(f) || f < 0 || f >= 1<<64 {
+				return false
+			}
+			u = uint64(f)
+		}
+		if value.OverflowUint(u) {
+			return false
+		}
+		value.SetUint(u)
+	default:
+		f, _ := numberValue(n)
+		if value.OverflowFloat(f) {
+			return false
+		}
+		value.SetFloat(f)
+	}
+	return true
+}
+
+// flagNameOf converts a configuration path into a flag name, e.g. "DB.MaxConns" into "db-max-conns".
+func flagNameOf(path string) string {
+	parts, _ := parsePath(path)
+	var buf bytes.Buffer
+	for _, part := range parts {
+		runes := []rune(part)
+		for i, r := range runes {
+			switch {
+			case r == '_' || r == '.' || r == ' ':
+				r = '-'
+			case i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1])):
+				buf.WriteByte('-')
+			}
+			buf.WriteRune(unicode.ToLower(r))
+		}
+		buf.WriteByte('-')
+	}
+	return strings.Trim(buf.String(), "-")
+}
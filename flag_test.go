@@ -0,0 +1,278 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBindFlags(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte(`{"DB": {"Host": "localhost", "Port": 3306, "Debug": true}}`))
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("db-host", "127.0.0.1", "")
+	fs.Int("db-port", 5432, "")
+	fs.Bool("db-debug", false, "")
+	fs.Duration("timeout", time.Second, "")
+	if err := fs.Parse([]string{"-db-port", "3307", "-timeout", "5s"}); err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+
+	err := c.BindFlags(fs, map[string]string{
+		"db-host":  "DB.Host",
+		"db-port":  "DB.Port",
+		"db-debug": "DB.Debug",
+		"timeout":  "Server.Timeout",
+	})
+	if err != nil {
+		t.Errorf("BindFlags(): %v", err)
+	}
+	expected := map[string]interface{}{
+		"DB": map[string]interface{}{
+			"Host":  "localhost",
+			"Port":  3307,
+			"Debug": true,
+		},
+		"Server": map[string]interface{}{
+			"Timeout": 5 * time.Second,
+		},
+	}
+	if !reflect.DeepEqual(c.Data(), expected) {
+		t.Errorf("Data() = %v, expected %v", c.Data(), expected)
+	}
+	if s := c.Source("DB.Port"); s != "BindFlags" {
+		t.Errorf(`Source("DB.Port") = %q, expected %q`, s, "BindFlags")
+	}
+	if s := c.Source("DB.Host"); s != "LoadJSON" {
+		t.Errorf(`Source("DB.Host") = %q, expected %q`, s, "LoadJSON")
+	}
+
+	if err := c.BindFlags(fs, map[string]string{"db-name": "DB.Name"}); err == nil {
+		t.Errorf("BindFlags() with an undefined flag: expected an error, got nil")
+	}
+	if err := c.BindFlags(fs, map[string]string{"db-port": "DB.Host.Port"}); err == nil {
+		t.Errorf("BindFlags() with an invalid path: expected an error, got nil")
+	}
+}
+
+func TestDefineFlags(t *testing.T) {
+	type Options struct {
+		Timeout time.Duration
+		Retries uint
+	}
+	type App struct {
+		Name string `usage:"the application name"`
+		DB   struct {
+			Host     string
+			MaxConns int
+			Ratio    float32
+			Debug    bool
+			Tags     []string
+		}
+		HTTPServer *Options
+		internal   string
+	}
+
+	c := New()
+	c.LoadJSON([]byte(`{"Name": "app", "DB": {"MaxConns": 20}, "HTTPServer": {"Timeout": "1m"}}`))
+
+	var app App
+	app.DB.Host = "localhost"
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	mapping, err := c.DefineFlags(fs, &app)
+	if err != nil {
+		t.Fatalf("DefineFlags(): %v", err)
+	}
+	expected := map[string]string{
+		"name":                "Name",
+		"db-host":             "DB.Host",
+		"db-max-conns":        "DB.MaxConns",
+		"db-ratio":            "DB.Ratio",
+		"db-debug":            "DB.Debug",
+		"http-server-timeout": "HTTPServer.Timeout",
+		"http-server-retries": "HTTPServer.Retries",
+	}
+	if !reflect.DeepEqual(mapping, expected) {
+		t.Errorf("DefineFlags() = %v, expected %v", mapping, expected)
+	}
+
+	defaults := map[string]string{
+		"name":                "app",
+		"db-host":             "localhost",
+		"db-max-conns":        "20",
+		"http-server-timeout": "1m0s",
+	}
+	for name, value := range defaults {
+		if f := fs.Lookup(name); f == nil || f.DefValue != value {
+			t.Errorf("the default value of -%v is not %q", name, value)
+		}
+	}
+	if f := fs.Lookup("name"); f == nil || f.Usage != "the application name" {
+		t.Errorf("the usage of -name is not set by the usage tag")
+	}
+
+	if err := fs.Parse([]string{"-db-max-conns=30", "-http-server-retries=3", "-db-debug"}); err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+	if err := c.BindFlags(fs, mapping); err != nil {
+		t.Errorf("BindFlags(): %v", err)
+	}
+	c.Set("HTTPServer.Timeout", time.Minute)
+	if err := c.Configure(&app); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+	if app.Name != "app" || app.DB.MaxConns != 30 || !app.DB.Debug || app.HTTPServer.Retries != 3 || app.HTTPServer.Timeout != time.Minute {
+		t.Errorf("Configure() = %+v", app)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	mapping, err = c.DefineFlags(fs, &app.DB, "DB")
+	if err != nil {
+		t.Errorf("DefineFlags(): %v", err)
+	} else if mapping["db-max-conns"] != "DB.MaxConns" {
+		t.Errorf("DefineFlags() with a path = %v", mapping)
+	}
+	if _, err := c.DefineFlags(fs, app); err == nil {
+		t.Errorf("DefineFlags() with a non-pointer: expected an error, got nil")
+	}
+
+	// the fields of embedded structs are promoted
+	var outer embedOuter
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	mapping, err = c.DefineFlags(fs, &outer)
+	if err != nil {
+		t.Errorf("DefineFlags(): %v", err)
+	} else if mapping["host"] != "Host" || mapping["user"] != "User" || mapping["level"] != "Level" || mapping["timeout"] != "" {
+		t.Errorf("DefineFlags() with embedded structs = %v", mapping)
+	}
+}
+
+func TestDefineFlagsDefaults(t *testing.T) {
+	os.Setenv("ZZPASS", "hunter2")
+	defer os.Unsetenv("ZZPASS")
+
+	c := New()
+	c.LoadJSON([]byte(`{
+		"Password": "secret://env/ZZPASS",
+		"Key": "k1",
+		"Port": "8080",
+		"Address": 8080,
+		"Retries": "3",
+		"Ratio": 2,
+		"Debug": "true",
+		"Count": 1.0
+	}`))
+	// Set() keeps an encrypted value as it is without the key
+	c.Set("Token", "ENC[AES256_GCM,data:abc,iv:def,tag:ghi,type:str]")
+	var app struct {
+		Password string
+		Token    string
+		Key      string `config:",secret"`
+		Port     int
+		Address  string
+		Retries  uint8
+		Ratio    float64
+		Debug    bool
+		Count    int
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := c.DefineFlags(fs, &app); err != nil {
+		t.Fatalf("DefineFlags(): %v", err)
+	}
+	defaults := map[string]string{
+		"password": "",
+		"token":    "",
+		"key":      "",
+		"port":     "8080",
+		"address":  "8080",
+		"retries":  "3",
+		"ratio":    "2",
+		"debug":    "true",
+		"count":    "1",
+	}
+	for name, value := range defaults {
+		if f := fs.Lookup(name); f == nil || f.DefValue != value {
+			t.Errorf("the default value of -%v is %q, expected %q", name, f.DefValue, value)
+		}
+	}
+
+	// the flags are not redefined
+	if _, err := c.DefineFlags(fs, &app); err == nil {
+		t.Errorf("DefineFlags() with the defined flags: expected an error, got nil")
+	}
+	var dup struct {
+		DBHost string
+		DB     struct{ Host string }
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := c.DefineFlags(fs, &dup); err == nil {
+		t.Errorf("DefineFlags() with the same flag name for two fields: expected an error, got nil")
+	} else if fs.Lookup("db-host") != nil {
+		t.Errorf("DefineFlags() defined flags before returning an error")
+	}
+
+	// the numbers that do not fit in the types of the fields are rejected
+	var numbers struct {
+		Int     int
+		Int8    int8
+		Uint    uint
+		Uint8   uint8
+		Int64   int64
+		Float32 float32
+	}
+	tests := []struct {
+		path  string
+		value interface{}
+	}{
+		{"Int", 1.5},
+		{"Int8", 128},
+		{"Uint", -1},
+		{"Uint8", 256.0},
+		{"Int64", 1e19},
+		{"Int64", uint64(1 << 63)},
+		{"Float32", 1e39},
+	}
+	for _, test := range tests {
+		c := New()
+		c.Set(test.path, test.value)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		_, err := c.DefineFlags(fs, &numbers)
+		if e, ok := err.(*ConfigValueError); !ok || e.Path != test.path {
+			t.Errorf("DefineFlags() with %v = %v: returned %v, expected a ConfigValueError", test.path, test.value, err)
+		}
+	}
+	c = New()
+	c.SetData(map[string]interface{}{"Int": -1.0, "Int8": -128, "Uint": uint8(3), "Uint8": 255.0, "Int64": -9.223372036854775808e18, "Float32": 1.5})
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := c.DefineFlags(fs, &numbers); err != nil {
+		t.Errorf("DefineFlags(): %v", err)
+	} else if f := fs.Lookup("int64"); f.DefValue != "-9223372036854775808" {
+		t.Errorf("the default value of -int64 is %q", f.DefValue)
+	}
+}
+
+func TestFlagNameOf(t *testing.T) {
+	tests := []struct {
+		path, expected string
+	}{
+		{"DB.Host", "db-host"},
+		{"DB.MaxConns", "db-max-conns"},
+		{"HTTPServer.TLSCert", "http-server-tls-cert"},
+		{"db_host", "db-host"},
+		{"Servers.0.Port", "servers-0-port"},
+	}
+	for _, test := range tests {
+		if name := flagNameOf(test.path); name != test.expected {
+			t.Errorf("flagNameOf(%q) = %q, expected %q", test.path, name, test.expected)
+		}
+	}
+}
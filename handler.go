@@ -0,0 +1,189 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HandlerOption configures the HTTP handler created by Handler().
+type HandlerOption func(*handler)
+
+// WithUpdates allows the configuration to be changed by PUT requests sent to the handler created by Handler().
+// The auth function is called for every PUT request, and the request is rejected with the status 401
+// if the function returns false.
+func WithUpdates(auth func(r *http.Request) bool) HandlerOption {
+	return func(h *handler) {
+		h.auth = auth
+	}
+}
+
+// WithRedactPatterns specifies the patterns of the paths whose values are masked by the handler created
+// by Handler(). See Redacted() for the syntax of the patterns. DefaultRedactPatterns are used by default.
+func WithRedactPatterns(patterns ...string) HandlerOption {
+	return func(h *handler) {
+		h.patterns = patterns
+	}
+}
+
+// handler serves the configuration data over HTTP.
+type handler struct {
+	config   *Config
+	auth     func(r *http.Request) bool
+	patterns []string
+	mu       sync.RWMutex
+}
+
+// Handler returns an HTTP handler that serves the configuration for inspection, which is typically
+// mounted at a debug endpoint such as "/debug/config".
+//
+// A GET request returns the configuration data with the sensitive values masked by Redacted().
+// The data are in YAML format if the Accept header of the request asks for YAML (e.g. "application/yaml"),
+// and in JSON format otherwise. The following query parameters are supported:
+//
+//   - path: the path to the part of the configuration to be returned, e.g. "?path=DB.Host".
+//     The status 404 is returned if the path does not exist.
+//   - provenance: if true, the response is an object with a "value" element holding the configuration data
+//     and a "sources" element holding the sources of the values as returned by Sources().
+//
+// If the handler is created with the WithUpdates() option, a PUT request sets the configuration value
+// at the given path to the value in the request body by calling Set(). The body is parsed as YAML if
+// its Content-Type is YAML, and as JSON otherwise. Other requests are rejected with the status 405.
+//
+// Note that Config is not safe for concurrent use. If updates are allowed, the application should
+// avoid accessing the configuration while it may be changed by the handler.
+func Handler(c *Config, options ...HandlerOption) http.Handler {
+	h := &handler{config: c}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// ServeHTTP handles the HTTP requests.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		h.get(w, r)
+	case r.Method == http.MethodPut && h.auth != nil:
+		h.put(w, r)
+	default:
+		if h.auth != nil {
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+		} else {
+			w.Header().Set("Allow", "GET, HEAD")
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// get responds with the redacted configuration data at the requested path.
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.write(w, r, r.URL.Query().Get("path"))
+}
+
+// put sets the configuration value at the requested path and responds with the new value.
+func (h *handler) put(w http.ResponseWriter, r *http.Request) {
+	if !h.auth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "the path parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var value interface{}
+	if isYAML(r.Header.Get("Content-Type")) {
+		err = unmarshalYAML(body, &value)
+	} else {
+		err = json.Unmarshal(body, &value)
+	}
+	if err != nil {
+		http.Error(w, "invalid value: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.config.Set(path, value); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.write(w, r, path)
+}
+
+// write responds with the redacted configuration data at the path.
+func (h *handler) write(w http.ResponseWriter, r *http.Request, path string) {
+	c := h.config
+	data := c.Redacted(h.patterns...)
+	if path != "" {
+		parts, err := parsePath(path)
+		if err != nil {
+			http.Error(w, (&ConfigPathError{Path: path, Message: err.Error()}).Error(), http.StatusBadRequest)
+			return
+		}
+		v := c.lookup(reflect.ValueOf(data), parts)
+		if !v.IsValid() {
+			http.Error(w, (&ConfigPathError{Path: path, Message: "no configuration value was found"}).Error(), http.StatusNotFound)
+			return
+		}
+		data = valueInterface(v)
+	}
+	if provenance, _ := strconv.ParseBool(r.URL.Query().Get("provenance")); provenance {
+		data = map[string]interface{}{
+			"value":   data,
+			"sources": c.Sources(path),
+		}
+	}
+
+	var bytes []byte
+	var err error
+	if isYAML(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", "application/yaml")
+		bytes, err = yaml.Marshal(data)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		if bytes, err = json.MarshalIndent(data, "", "  "); err == nil {
+			bytes = append(bytes, '\n')
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(bytes)
+}
+
+// isYAML checks if a media type or a list of media types in an Accept header refers to YAML.
+func isYAML(mediaType string) bool {
+	for _, t := range strings.Split(mediaType, ",") {
+		if i := strings.IndexByte(t, ';'); i >= 0 {
+			t = t[:i]
+		}
+		switch strings.ToLower(strings.TrimSpace(t)) {
+		case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+			return true
+		}
+	}
+	return false
+}
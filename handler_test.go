@@ -0,0 +1,123 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	c := New()
+	c.Load("testdata/c1.json")
+	c.LoadJSON([]byte(`{"DB": {"Host": "localhost", "Password": "pass"}}`))
+	h := Handler(c)
+
+	tests := []struct {
+		tag         string
+		method      string
+		url         string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"t1", "GET", "/debug/config?path=DB", "", http.StatusOK, "application/json", "{\n  \"Host\": \"localhost\",\n  \"Password\": \"******\"\n}\n"},
+		{"t2", "GET", "/debug/config?path=DB", "application/yaml", http.StatusOK, "application/yaml", "Host: localhost\nPassword: '******'\n"},
+		{"t3", "GET", "/debug/config?path=DB.Host", "text/html, application/x-yaml;q=0.9", http.StatusOK, "application/yaml", "localhost\n"},
+		{"t4", "GET", "/debug/config?path=A7[1]", "", http.StatusOK, "application/json", "\"d2\"\n"},
+		{"t5", "GET", "/debug/config?path=DB&provenance=true", "", http.StatusOK, "application/json", "{\n  \"sources\": {\n    \"DB.Host\": \"LoadJSON\",\n    \"DB.Password\": \"LoadJSON\"\n  },\n  \"value\": {\n    \"Host\": \"localhost\",\n    \"Password\": \"******\"\n  }\n}\n"},
+		{"t6", "GET", "/debug/config?path=DB.Port", "", http.StatusNotFound, "text/plain; charset=utf-8", "\"DB.Port\" is not a valid path: no configuration value was found\n"},
+		{"t7", "GET", "/debug/config?path=DB[", "", http.StatusBadRequest, "text/plain; charset=utf-8", "\"DB[\" is not a valid path: unterminated bracket at position 2\n"},
+		{"t8", "PUT", "/debug/config?path=DB.Host", "", http.StatusMethodNotAllowed, "text/plain; charset=utf-8", "method not allowed\n"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.url, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if res.Code != test.status {
+			t.Errorf("%v: status = %v, expected %v", test.tag, res.Code, test.status)
+		}
+		if ct := res.Header().Get("Content-Type"); ct != test.contentType {
+			t.Errorf("%v: Content-Type = %q, expected %q", test.tag, ct, test.contentType)
+		}
+		if body := res.Body.String(); body != test.body {
+			t.Errorf("%v: body = %q, expected %q", test.tag, body, test.body)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest("GET", "/debug/config", nil))
+	if body := res.Body.String(); !strings.Contains(body, `"A1": "a1"`) || strings.Contains(body, "pass\"") {
+		t.Errorf("body = %q, expected the redacted configuration", body)
+	}
+}
+
+func TestHandlerWithUpdates(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte(`{"DB": {"Host": "localhost", "Token": "abc"}}`))
+	h := Handler(c, WithRedactPatterns("DB.Host"), WithUpdates(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer admin"
+	}))
+
+	tests := []struct {
+		tag         string
+		url         string
+		auth        string
+		contentType string
+		body        string
+		status      int
+		response    string
+	}{
+		{"t1", "/debug/config?path=DB.Port", "", "", "8080", http.StatusUnauthorized, "unauthorized\n"},
+		{"t2", "/debug/config?path=DB.Port", "Bearer admin", "application/json", "8080", http.StatusOK, "8080\n"},
+		{"t3", "/debug/config?path=DB.Options", "Bearer admin", "application/yaml", "Timeout: 10\n", http.StatusOK, "{\n  \"Timeout\": 10\n}\n"},
+		{"t4", "/debug/config?path=DB.Host", "Bearer admin", "", `"db.example.com"`, http.StatusOK, "\"******\"\n"},
+		{"t5", "/debug/config?path=DB.Port", "Bearer admin", "", "{", http.StatusBadRequest, "invalid value: unexpected end of JSON input\n"},
+		{"t6", "/debug/config", "Bearer admin", "", "1", http.StatusBadRequest, "the path parameter is required\n"},
+		{"t7", "/debug/config?path=DB.Host.X", "Bearer admin", "", "1", http.StatusBadRequest, "\"DB.Host.X\" is not a valid path: got string instead of a map, array, or slice\n"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("PUT", test.url, strings.NewReader(test.body))
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if res.Code != test.status {
+			t.Errorf("%v: status = %v, expected %v", test.tag, res.Code, test.status)
+		}
+		if body := res.Body.String(); body != test.response {
+			t.Errorf("%v: body = %q, expected %q", test.tag, body, test.response)
+		}
+	}
+
+	if v := c.GetInt("DB.Port"); v != 8080 {
+		t.Errorf("DB.Port = %v, expected %v", v, 8080)
+	}
+	if v := c.GetString("DB.Host"); v != "db.example.com" {
+		t.Errorf("DB.Host = %v, expected %v", v, "db.example.com")
+	}
+	if v := c.GetInt("DB.Options.Timeout"); v != 10 {
+		t.Errorf("DB.Options.Timeout = %v, expected %v", v, 10)
+	}
+	if v := c.Source("DB.Port"); v != "Set" {
+		t.Errorf(`Source("DB.Port") = %q, expected %q`, v, "Set")
+	}
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest("DELETE", "/debug/config?path=DB", nil))
+	if res.Code != http.StatusMethodNotAllowed || res.Header().Get("Allow") != "GET, HEAD, PUT" {
+		t.Errorf("DELETE: status = %v, Allow = %q", res.Code, res.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,500 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// unmarshalHCL parses the given HCL data and populates it into the given variable.
+//
+// Attributes such as `port = 80` become map elements, and blocks become nested maps, with the block labels
+// used as additional keys. For example, the attribute "port" in the block `service "web" { ... }`
+// corresponds to the path "service.web.port". If a block with the same type and labels appears multiple
+// times, the blocks are turned into an array.
+//
+// Only literal expressions are supported: numbers, strings, heredocs, true, false, null, tuples, and objects.
+// Strings containing template interpolations such as "${var.name}" are rejected.
+func unmarshalHCL(bytes []byte, data interface{}) error {
+	body, _, err := parseHCL(bytes)
+	if err != nil {
+		return err
+	}
+	return populate(data, body)
+}
+
+// parseHCL parses the given HCL data as described in unmarshalHCL() and returns the parsed value
+// together with the positions of the values, indexed by their paths.
+func parseHCL(bytes []byte) (interface{}, map[string]Position, error) {
+	p := &hclParser{s: string(bytes), blocks: map[string]bool{}, offsets: map[string]int{}}
+	body, err := p.parseBody("", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, offsetPositions(p.s, p.offsets), nil
+}
+
+// hclParser parses HCL data.
+type hclParser struct {
+	s       string          // the data
+	i       int             // the current position
+	blocks  map[string]bool // the paths of the blocks parsed so far
+	offsets map[string]int  // the offsets of the values in the body being parsed, indexed by their paths in the body
+}
+
+// errorf returns an error describing a problem found at the current position.
+func (p *hclParser) errorf(format string, args ...interface{}) error {
+	return errorAt(p.s, p.i, format, args...)
+}
+
+// parseBody parses the attributes and blocks in a body located at the path.
+// If nested is true, the body is enclosed in braces and the opening brace has been consumed.
+// The offsets of the values are recorded with their paths relative to the body.
+func (p *hclParser) parseBody(path string, nested bool) (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	for {
+		p.skipSpaces(true)
+		if p.i == len(p.s) {
+			if nested {
+				return nil, p.errorf("missing '}'")
+			}
+			return body, nil
+		}
+		if nested && p.s[p.i] == '}' {
+			p.i++
+			return body, nil
+		}
+
+		start := p.i
+		name := p.parseIdentifier()
+		if name == "" {
+			return nil, p.errorf("unexpected character %q", p.s[p.i])
+		}
+		p.skipSpaces(false)
+		if p.i < len(p.s) && p.s[p.i] == '=' {
+			p.i++
+			value, err := p.parseExpr(joinPath("", name))
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := body[name]; ok {
+				p.i = start
+				return nil, p.errorf("duplicate attribute %q", name)
+			}
+			body[name] = value
+			if err := p.parseLineEnd(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		keys := []string{name}
+		for p.i < len(p.s) && p.s[p.i] != '{' {
+			var label string
+			var err error
+			if p.s[p.i] == '"' {
+				label, err = p.parseString()
+			} else if label = p.parseIdentifier(); label == "" {
+				err = p.errorf("expected '=', '{', or a block label after %q", name)
+			}
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, label)
+			p.skipSpaces(false)
+		}
+		if p.i == len(p.s) {
+			return nil, p.errorf("missing '{' after the block %q", name)
+		}
+		p.i++
+		blockPath := path
+		for _, key := range keys {
+			blockPath = joinPath(blockPath, key)
+		}
+		offsets := p.offsets
+		p.offsets = map[string]int{}
+		block, err := p.parseBody(blockPath, true)
+		if err != nil {
+			return nil, err
+		}
+		offsets, p.offsets = p.offsets, offsets
+		end := p.i
+		p.i = start
+		if err := p.addBlock(body, keys, blockPath, block, offsets); err != nil {
+			return nil, err
+		}
+		p.i = end
+		if err := p.parseLineEnd(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// addBlock adds a block to the body. The keys are the block type followed by the block labels.
+// The offsets of the values in the block are added to those of the body.
+func (p *hclParser) addBlock(body map[string]interface{}, keys []string, path string, block map[string]interface{}, offsets map[string]int) error {
+	m, err := childMap(body, keys[:len(keys)-1])
+	if err != nil {
+		return p.errorf("%v", err)
+	}
+	key := keys[len(keys)-1]
+	rel := PathOf(keys...)
+	switch old := m[key].(type) {
+	case nil:
+		if _, ok := m[key]; ok {
+			return p.errorf("block %q conflicts with an attribute", path)
+		}
+		m[key] = block
+		p.addOffsets(rel, offsets)
+	case []interface{}:
+		if !p.blocks[path] {
+			return p.errorf("block %q conflicts with an attribute", path)
+		}
+		m[key] = append(old, block)
+		p.addOffsets(joinPath(rel, strconv.Itoa(len(old))), offsets)
+	case map[string]interface{}:
+		if p.blocks[path] {
+			m[key] = []interface{}{old, block}
+			p.moveOffsets(rel, joinPath(rel, "0"))
+			p.addOffsets(joinPath(rel, "1"), offsets)
+			break
+		}
+		p.addOffsets(rel, offsets)
+		// the map was created for the blocks with more labels
+		for k, v := range block {
+			if _, ok := old[k]; ok {
+				return p.errorf("duplicate attribute %q in block %q", k, path)
+			}
+			old[k] = v
+		}
+	default:
+		return p.errorf("block %q conflicts with an attribute", path)
+	}
+	p.blocks[path] = true
+	return nil
+}
+
+// addOffsets records the offsets of a block located at the path, which starts at the current position.
+func (p *hclParser) addOffsets(path string, offsets map[string]int) {
+	p.offsets[path] = p.i
+	for k, offset := range offsets {
+		p.offsets[path+"."+k] = offset
+	}
+}
+
+// moveOffsets moves the offsets of the value at the path "from" and the values under it to the path "to".
+func (p *hclParser) moveOffsets(from, to string) {
+	moved := map[string]int{}
+	for k, offset := range p.offsets {
+		if k == from || strings.HasPrefix(k, from+".") {
+			delete(p.offsets, k)
+			moved[to+k[len(from):]] = offset
+		}
+	}
+	for k, offset := range moved {
+		p.offsets[k] = offset
+	}
+}
+
+// parseLineEnd checks that an attribute or a block is followed by a line break, a comment, or a closing brace.
+func (p *hclParser) parseLineEnd() error {
+	p.skipSpaces(false)
+	if p.i < len(p.s) && p.s[p.i] != '\n' && p.s[p.i] != '}' {
+		return p.errorf("unexpected character %q, expected a line break", p.s[p.i])
+	}
+	return nil
+}
+
+// parseExpr parses a literal expression whose value is located at the path.
+func (p *hclParser) parseExpr(path string) (interface{}, error) {
+	p.skipSpaces(false)
+	if p.i == len(p.s) {
+		return nil, p.errorf("missing the value")
+	}
+	p.offsets[path] = p.i
+	switch c := p.s[p.i]; {
+	case c == '"':
+		return p.parseString()
+	case strings.HasPrefix(p.s[p.i:], "<<"):
+		return p.parseHeredoc()
+	case c == '[':
+		return p.parseTuple(path)
+	case c == '{':
+		return p.parseObject(path)
+	case c == '-' || c >= '0' && c <= '9':
+		return p.parseNumber()
+	}
+
+	start := p.i
+	switch name := p.parseIdentifier(); name {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		return nil, p.errorf("unexpected character %q", p.s[p.i])
+	default:
+		p.i = start
+		return nil, p.errorf("unsupported expression %q: only literal values are allowed", name)
+	}
+}
+
+// parseNumber parses a number literal.
+func (p *hclParser) parseNumber() (interface{}, error) {
+	start := p.i
+	isFloat := false
+	if p.s[p.i] == '-' {
+		p.i++
+	}
+loop:
+	for ; p.i < len(p.s); p.i++ {
+		switch c := p.s[p.i]; {
+		case c >= '0' && c <= '9':
+		case c == '.' || c == 'e' || c == 'E':
+			isFloat = true
+		case (c == '+' || c == '-') && (p.s[p.i-1] == 'e' || p.s[p.i-1] == 'E'):
+		default:
+			break loop
+		}
+	}
+	s := p.s[start:p.i]
+	if !isFloat {
+		if i, err := strconv.Atoi(s); err == nil {
+			return i, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		p.i = start
+		return nil, p.errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+// parseString parses a quoted string.
+func (p *hclParser) parseString() (string, error) {
+	var buf bytes.Buffer
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch c := p.s[p.i]; c {
+		case '"':
+			p.i++
+			return buf.String(), nil
+		case '\n':
+			return "", p.errorf("unterminated string")
+		case '\\':
+			if p.i+1 == len(p.s) {
+				return "", p.errorf("unterminated string")
+			}
+			p.i++
+			switch e := p.s[p.i]; e {
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case '"', '\\':
+				buf.WriteByte(e)
+			case 'u', 'U':
+				n := 4
+				if e == 'U' {
+					n = 8
+				}
+				if p.i+1+n > len(p.s) {
+					return "", p.errorf("invalid escape sequence")
+				}
+				r, err := strconv.ParseUint(p.s[p.i+1:p.i+1+n], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid escape sequence")
+				}
+				buf.WriteRune(rune(r))
+				p.i += n
+			default:
+				return "", p.errorf("invalid escape sequence \\%c", e)
+			}
+		case '$', '%':
+			if strings.HasPrefix(p.s[p.i+1:], "{") {
+				return "", p.errorf("unsupported template sequence %q: only literal values are allowed", p.s[p.i:p.i+2])
+			}
+			if strings.HasPrefix(p.s[p.i+1:], string(c)+"{") {
+				p.i++
+			}
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseHeredoc parses a heredoc string such as "<<EOT\n...\nEOT". If the heredoc starts with "<<-",
+// the common leading whitespace of the lines is removed.
+func (p *hclParser) parseHeredoc() (string, error) {
+	start := p.i
+	p.i += 2
+	indented := false
+	if p.i < len(p.s) && p.s[p.i] == '-' {
+		indented = true
+		p.i++
+	}
+	marker := p.parseIdentifier()
+	if marker == "" || p.i == len(p.s) || p.s[p.i] != '\n' && !strings.HasPrefix(p.s[p.i:], "\r\n") {
+		p.i = start
+		return "", p.errorf("invalid heredoc")
+	}
+	p.i = strings.IndexByte(p.s[p.i:], '\n') + p.i + 1
+
+	var lines []string
+	for p.i < len(p.s) {
+		end := strings.IndexByte(p.s[p.i:], '\n')
+		if end < 0 {
+			end = len(p.s) - p.i
+		}
+		line := strings.TrimSuffix(p.s[p.i:p.i+end], "\r")
+		if strings.TrimSpace(line) == marker {
+			p.i += len(strings.TrimRight(p.s[p.i:p.i+end], "\r \t"))
+			if indented {
+				lines = trimIndent(lines)
+			}
+			s := strings.Join(lines, "")
+			if i := strings.Index(s, "${"); i >= 0 && (i == 0 || s[i-1] != '$') {
+				p.i = start
+				return "", p.errorf("unsupported template sequence %q: only literal values are allowed", "${")
+			}
+			return strings.Replace(s, "$${", "${", -1), nil
+		}
+		lines = append(lines, line+"\n")
+		p.i += end + 1
+	}
+	p.i = start
+	return "", p.errorf("unterminated heredoc %q", marker)
+}
+
+// trimIndent removes the common leading whitespace of the lines.
+func trimIndent(lines []string) []string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[i] = line[indent:]
+		}
+	}
+	return lines
+}
+
+// parseTuple parses a tuple such as `[1, "a"]` located at the path.
+func (p *hclParser) parseTuple(path string) (interface{}, error) {
+	tuple := []interface{}{}
+	for p.i++; ; {
+		p.skipSpaces(true)
+		if p.i < len(p.s) && p.s[p.i] == ']' {
+			p.i++
+			return tuple, nil
+		}
+		value, err := p.parseExpr(joinPath(path, strconv.Itoa(len(tuple))))
+		if err != nil {
+			return nil, err
+		}
+		tuple = append(tuple, value)
+		p.skipSpaces(true)
+		if p.i < len(p.s) && p.s[p.i] == ',' {
+			p.i++
+		} else if p.i == len(p.s) || p.s[p.i] != ']' {
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+// parseObject parses an object such as `{ a = 1, "b" = 2 }` located at the path.
+func (p *hclParser) parseObject(path string) (interface{}, error) {
+	object := map[string]interface{}{}
+	for p.i++; ; {
+		p.skipSpaces(true)
+		if p.i < len(p.s) && p.s[p.i] == '}' {
+			p.i++
+			return object, nil
+		}
+		start := p.i
+		var key string
+		var err error
+		if p.i < len(p.s) && p.s[p.i] == '"' {
+			key, err = p.parseString()
+		} else if key = p.parseIdentifier(); key == "" {
+			err = p.errorf("expected an object key")
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces(false)
+		if p.i == len(p.s) || p.s[p.i] != '=' && p.s[p.i] != ':' {
+			return nil, p.errorf("expected '=' after the object key %q", key)
+		}
+		p.i++
+		value, err := p.parseExpr(joinPath(path, key))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := object[key]; ok {
+			p.i = start
+			return nil, p.errorf("duplicate object key %q", key)
+		}
+		object[key] = value
+		p.skipSpaces(false)
+		if p.i < len(p.s) && (p.s[p.i] == ',' || p.s[p.i] == '\n') {
+			p.i++
+		} else if p.i == len(p.s) || p.s[p.i] != '}' {
+			return nil, p.errorf("expected ',', a line break, or '}'")
+		}
+	}
+}
+
+// parseIdentifier parses an identifier. An empty string is returned if there is no identifier at the current position.
+func (p *hclParser) parseIdentifier() string {
+	start := p.i
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80 ||
+			p.i > start && (c >= '0' && c <= '9' || c == '-') {
+			p.i++
+		} else {
+			break
+		}
+	}
+	return p.s[start:p.i]
+}
+
+// skipSpaces skips whitespace and comments. Line breaks are skipped only if newlines is true.
+func (p *hclParser) skipSpaces(newlines bool) {
+	for p.i < len(p.s) {
+		switch c := p.s[p.i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' && newlines:
+			p.i++
+		case c == '#' || strings.HasPrefix(p.s[p.i:], "//"):
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+		case strings.HasPrefix(p.s[p.i:], "/*"):
+			if end := strings.Index(p.s[p.i+2:], "*/"); end >= 0 {
+				p.i += end + 4
+			} else {
+				p.i = len(p.s)
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalHCL(t *testing.T) {
+	tests := []struct {
+		hcl      string
+		expected string
+	}{
+		{"", `{}`},
+		{"a = 1\nb = -2.5\nc = 1e3\nd = \"x\"\ne = true\nf = false\ng = null", `{"a":1,"b":-2.5,"c":1000,"d":"x","e":true,"f":false,"g":null}`},
+		{"# comment\n// comment\n/* multi\nline */ a = 1 # comment\nb = 2 // comment", `{"a":1,"b":2}`},
+		{`a = "x\ty\n\"z\"\\ é $${x} %%{y} $x"`, `{"a":"x\ty\n\"z\"\\ é ${x} %{y} $x"}`},
+		{"a = [1, \"x\", [true],\n  null,\n]\nb = []", `{"a":[1,"x",[true],null],"b":[]}`},
+		{"a = { x = 1, \"y.z\" : \"w\" }\nb = {\n  c = [1]\n  d = {}\n}", `{"a":{"x":1,"y.z":"w"},"b":{"c":[1],"d":{}}}`},
+		{"server {\n  port = 80\n  tls {\n    enabled = true\n  }\n}", `{"server":{"port":80,"tls":{"enabled":true}}}`},
+		{"service \"web\" {\n  port = 80\n}\nservice \"api\" \"v1\" { port = 81 }", `{"service":{"api":{"v1":{"port":81}},"web":{"port":80}}}`},
+		{"service web {\n  port = 80\n}", `{"service":{"web":{"port":80}}}`},
+		{"listener { port = 80 }\nlistener { port = 81 }\nlistener { port = 82 }", `{"listener":[{"port":80},{"port":81},{"port":82}]}`},
+		{"service \"web\" { port = 80 }\nservice { name = \"x\" }", `{"service":{"name":"x","web":{"port":80}}}`},
+		{"a = <<EOT\nline1\n  line2\nEOT\nb = 1", `{"a":"line1\n  line2\n","b":1}`},
+		{"a = <<-EOT\n    line1\n      line2\n    EOT\n", `{"a":"line1\n  line2\n"}`},
+		{"a = 1\r\nb {\r\n  c = \"x\"\r\n}\r\n", `{"a":1,"b":{"c":"x"}}`},
+		{"a-b = 1\nc_d = 2", `{"a-b":1,"c_d":2}`},
+	}
+	for _, test := range tests {
+		var data interface{}
+		if err := unmarshalHCL([]byte(test.hcl), &data); err != nil {
+			t.Errorf("unmarshalHCL(%q): %v", test.hcl, err)
+			continue
+		}
+		if s, _ := json.Marshal(data); string(s) != test.expected {
+			t.Errorf("unmarshalHCL(%q) = %v, expected %v", test.hcl, string(s), test.expected)
+		}
+	}
+
+	errors := []struct {
+		hcl      string
+		expected string
+	}{
+		{"a", `1:2: missing '{' after the block "a"`},
+		{"a b.c", `1:4: expected '=', '{', or a block label after "a"`},
+		{"a = 1\n= 2", `2:1: unexpected character '='`},
+		{"a = 1 2", `1:7: unexpected character '2', expected a line break`},
+		{"a = 1\na = 2", `2:1: duplicate attribute "a"`},
+		{"a = var.x", `1:5: unsupported expression "var": only literal values are allowed`},
+		{"a = upper(\"x\")", `1:5: unsupported expression "upper": only literal values are allowed`},
+		{`a = "${var.x}"`, `1:6: unsupported template sequence "${": only literal values are allowed`},
+		{"a = \"x\nb = 1", "1:7: unterminated string"},
+		{`a = "\q"`, `1:7: invalid escape sequence \q`},
+		{"a = [1 2]", `1:8: expected ',' or ']'`},
+		{"a = {x = 1 y = 2}", `1:12: expected ',', a line break, or '}'`},
+		{"a = {x 1}", `1:8: expected '=' after the object key "x"`},
+		{"a = {x = 1, x = 2}", `1:13: duplicate object key "x"`},
+		{"a = <<EOT\nx\n", `1:5: unterminated heredoc "EOT"`},
+		{"a = <<EOT\n${x}\nEOT", `1:5: unsupported template sequence "${": only literal values are allowed`},
+		{"a = -", `1:5: invalid number "-"`},
+		{"a =", `1:4: missing the value`},
+		{"b {\n  c = 1\n", `3:1: missing '}'`},
+		{"b \"x\"", `1:6: missing '{' after the block "b"`},
+		{"a = 1\na { b = 1 }", `2:1: block "a" conflicts with an attribute`},
+		{"a = 1\na \"x\" { b = 1 }", `2:1: "a" is not a map`},
+		{"a \"x\" { b = 1 }\na { x = 2 }", `2:1: duplicate attribute "x" in block "a"`},
+	}
+	for _, test := range errors {
+		var data interface{}
+		if err := unmarshalHCL([]byte(test.hcl), &data); err == nil || err.Error() != test.expected {
+			t.Errorf("unmarshalHCL(%q) error = %v, expected %v", test.hcl, err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"reflect"
+)
+
+// DecodeHook converts a configuration value before it is used by Configure() to configure a value of the type "to".
+//
+// The "from" parameter is the type of the configuration value "data". The hook returns the converted value,
+// or "data" itself if it does not handle the conversion. If an error is returned, Configure() fails with
+// a ConfigValueError for the path of the configuration value.
+type DecodeHook func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error)
+
+// RegisterDecodeHook registers a hook that converts configuration values before they are used by Configure().
+//
+// The hooks are called in the order they are registered, each with the value returned by the previous one,
+// for every configuration value used to configure a value, including maps and arrays. If the hooks convert the
+// configuration value into a different type that can be assigned to the value being configured, the value is set
+// directly with the converted one. Otherwise, the converted value is used to configure the value in the usual way.
+//
+// For example, the following hook allows a comma-separated string to configure a string slice:
+//
+//	c.RegisterDecodeHook(func(from, to reflect.Type, data interface{}) (interface{}, error) {
+//		if from.Kind() == reflect.String && to == reflect.TypeOf([]string{}) {
+//			return strings.Split(data.(string), ","), nil
+//		}
+//		return data, nil
+//	})
+func (c *Config) RegisterDecodeHook(hook DecodeHook) {
+	c.hooks = append(c.hooks, hook)
+}
+
+// decode calls the decode hooks with the configuration value used to configure the value at the path.
+// It returns the converted configuration value and whether the value has been set with it.
+func (c *configurer) decode(v, config reflect.Value, path string) (reflect.Value, bool, error) {
+	converted, direct, err := c.convert(v, config, path)
+	if err != nil || !direct {
+		return converted, false, err
+	}
+	v.Set(converted)
+	return config, true, nil
+}
+
+// convert calls the decode hooks with the configuration value used to configure the value at the path,
+// without modifying the value. It returns the converted configuration value and whether the value can be
+// set directly with it.
+func (c *configurer) convert(v, config reflect.Value, path string) (reflect.Value, bool, error) {
+	if len(c.hooks) == 0 || !config.IsValid() {
+		return config, false, nil
+	}
+	from, data := config.Type(), config.Interface()
+	for _, hook := range c.hooks {
+		var err error
+		if data, err = hook(from, v.Type(), data); err != nil {
+			return config, false, c.valueError(path, err.Error())
+		}
+		if data == nil {
+			return reflect.Value{}, false, nil
+		}
+		from = reflect.TypeOf(data)
+	}
+	if from != config.Type() && from.AssignableTo(v.Type()) && v.CanSet() {
+		return reflect.ValueOf(data), true, nil
+	}
+	return reflect.ValueOf(data), false, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type hookedEndpoint struct {
+	Address string
+	Secure  bool
+}
+
+type hookedTarget struct {
+	Hosts    []string
+	Pattern  *regexp.Regexp
+	Endpoint *hookedEndpoint
+	Ports    []int
+	Labels   map[string]string
+}
+
+func splitHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() == reflect.String && to == reflect.TypeOf([]string{}) {
+		return strings.Split(data.(string), ","), nil
+	}
+	return data, nil
+}
+
+func regexpHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() == reflect.String && to == reflect.TypeOf(&regexp.Regexp{}) {
+		return regexp.Compile(data.(string))
+	}
+	return data, nil
+}
+
+func endpointHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() == reflect.Map && to == reflect.TypeOf(&hookedEndpoint{}) {
+		m := data.(map[string]interface{})
+		if m["path"] == nil {
+			return nil, errors.New("the path is required")
+		}
+		return &hookedEndpoint{Address: "unix://" + m["path"].(string), Secure: true}, nil
+	}
+	return data, nil
+}
+
+func TestDecodeHook(t *testing.T) {
+	c := New()
+	c.RegisterDecodeHook(splitHook)
+	c.RegisterDecodeHook(regexpHook)
+	c.RegisterDecodeHook(endpointHook)
+	c.LoadJSON([]byte(`{
+		"Hosts": "a,b,c",
+		"Pattern": "^a+$",
+		"Endpoint": {"path": "/run/app.sock"},
+		"Ports": [80, 443],
+		"Labels": {"x": "y"}
+	}`))
+
+	var v hookedTarget
+	if err := c.Configure(&v); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	if !reflect.DeepEqual(v.Hosts, []string{"a", "b", "c"}) {
+		t.Errorf("Hosts = %v, expected %v", v.Hosts, []string{"a", "b", "c"})
+	}
+	if v.Pattern == nil || !v.Pattern.MatchString("aaa") {
+		t.Errorf("Pattern = %v, expected %v", v.Pattern, "^a+$")
+	}
+	if v.Endpoint == nil || v.Endpoint.Address != "unix:///run/app.sock" || !v.Endpoint.Secure {
+		t.Errorf("Endpoint = %v, expected the address unix:///run/app.sock", v.Endpoint)
+	}
+	if !reflect.DeepEqual(v.Ports, []int{80, 443}) || v.Labels["x"] != "y" {
+		t.Errorf("Ports = %v, Labels = %v, expected the values to be configured as usual", v.Ports, v.Labels)
+	}
+	if unused := c.Unused(); len(unused) != 0 {
+		t.Errorf("Unused() = %v, expected none", unused)
+	}
+
+	// the hooks are kept by Clone() and their errors are reported with the paths
+	c = c.Clone()
+	c.Set("Pattern", "(")
+	err := c.Configure(&v)
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "Pattern" {
+		t.Errorf("Configure() returned %v, expected a ConfigValueError for %q", err, "Pattern")
+	}
+	c.Set("Pattern", "a")
+	c.Set("Endpoint", map[string]interface{}{"Address": "x"})
+	err = c.Configure(&v)
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "Endpoint" || e.Message != "the path is required" {
+		t.Errorf("Configure() returned %v, expected a ConfigValueError for %q", err, "Endpoint")
+	}
+
+	// without the hooks, the values cannot be converted
+	c = New()
+	c.SetData(map[string]interface{}{"Hosts": "a,b"})
+	if err := c.Configure(&v); err == nil {
+		t.Errorf("Configure() expected an error without the hooks, got nil")
+	}
+}
+
+func TestDecodeHookChain(t *testing.T) {
+	c := New()
+	c.RegisterDecodeHook(func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if s, ok := data.(string); ok {
+			return strings.TrimSpace(s), nil
+		}
+		return data, nil
+	})
+	c.RegisterDecodeHook(splitHook)
+	c.SetData(map[string]interface{}{"Hosts": " a,b ", "Name": " x "})
+	var v struct {
+		Hosts []string
+		Name  string
+	}
+	if err := c.Configure(&v); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	if !reflect.DeepEqual(v.Hosts, []string{"a", "b"}) || v.Name != "x" {
+		t.Errorf("Configure() = %+v, expected the hooks to be applied in order", v)
+	}
+}
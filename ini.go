@@ -0,0 +1,308 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// unmarshalINI parses the given INI data and populates it into the given variable.
+//
+// Sections become nested maps, and dotted section names and keys become paths, so that
+// "[A6.B2]" followed by "C1 = c1" is the same as "A6.B2.C1 = c1". If a key is also a section name or
+// the prefix of other keys, its value is keyed by PrefixValueKey in the map of the key. Keys ending with "[]",
+// such as "A7[] = d1", append the values to arrays, and indexed keys such as "A7.0 = d1" and "A7.1 = d2"
+// populate an array if the indexes of the array start from 0 without gaps. Lines starting with ";" or "#" are comments,
+// and so is the text following " ;" or " #" in an unquoted value. A line ending with a backslash
+// is continued on the next line.
+//
+// Values enclosed in double quotes may contain the escape sequences allowed in Go strings, while
+// values enclosed in single quotes are taken literally. Unquoted values that are integers, floats,
+// true, or false are converted into the corresponding types, and the rest are kept as strings.
+func unmarshalINI(bytes []byte, data interface{}) error {
+	root, _, err := parseINI(bytes)
+	if err != nil {
+		return err
+	}
+	return populate(data, root)
+}
+
+// parseINI parses the given INI data as described in unmarshalINI() and returns the parsed value
+// together with the positions of the values, indexed by their paths.
+func parseINI(bytes []byte) (interface{}, map[string]Position, error) {
+	root := map[string]interface{}{}
+	positions := map[string]Position{}
+	section := []string{}
+	lines := splitLines(bytes)
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		pos := Position{Line: n, Column: indentOf(lines[i], " \t") + 1}
+		line := strings.TrimSpace(lines[i])
+		for hasContinuation(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimSpace(lines[i])
+		}
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, nil, errorAtLine(n, "unterminated section name")
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != ';' && rest[0] != '#' {
+				return nil, nil, errorAtLine(n, "unexpected %q after the section name", rest)
+			}
+			name := strings.TrimSpace(line[1:end])
+			if name == "" {
+				return nil, nil, errorAtLine(n, "empty section name")
+			}
+			keys, err := parsePath(name)
+			if err != nil {
+				return nil, nil, errorAtLine(n, "invalid section name %q: %v", name, err)
+			}
+			if _, err := prefixMap(root, keys); err != nil {
+				return nil, nil, errorAtLine(n, "%v", err)
+			}
+			positions[PathOf(keys...)] = pos
+			section = keys
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			return nil, nil, errorAtLine(n, "missing '=' after the key %q", line)
+		}
+		key := strings.TrimSpace(line[:sep])
+		isArray := strings.HasSuffix(key, "[]")
+		key = strings.TrimSpace(strings.TrimSuffix(key, "[]"))
+		if key == "" {
+			return nil, nil, errorAtLine(n, "missing the key")
+		}
+		keys, err := parsePath(key)
+		if err != nil {
+			return nil, nil, errorAtLine(n, "invalid key %q: %v", key, err)
+		}
+		value, err := iniValue(strings.TrimSpace(line[sep+1:]))
+		if err != nil {
+			return nil, nil, errorAtLine(n, "%v", err)
+		}
+		path, err := setValue(root, append(section[:len(section):len(section)], keys...), value, isArray)
+		if err != nil {
+			return nil, nil, errorAtLine(n, "%v", err)
+		}
+		positions[path] = pos
+	}
+	indexArrays(root)
+	return root, positions, nil
+}
+
+// iniValue parses an INI value which may be quoted and followed by a comment.
+func iniValue(s string) (interface{}, error) {
+	if s == "" {
+		return "", nil
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		end := 1
+		for ; end < len(s) && s[end] != s[0]; end++ {
+			if s[0] == '"' && s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return nil, errors.New("unterminated quoted value")
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && rest[0] != ';' && rest[0] != '#' {
+			return nil, fmt.Errorf("unexpected %q after the quoted value", rest)
+		}
+		if s[0] == '\'' {
+			return s[1:end], nil
+		}
+		value, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted value %v", s[:end+1])
+		}
+		return value, nil
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && strings.IndexByte(`\;#`, s[i+1]) >= 0 {
+			i++
+			c = s[i]
+		} else if (c == ';' || c == '#') && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t') {
+			break
+		}
+		buf.WriteByte(c)
+	}
+	return scalarValue(strings.TrimSpace(buf.String())), nil
+}
+
+// scalarValue converts a string into an integer, a float, or a boolean if the string is the canonical
+// representation of such a value. Otherwise, the string itself is returned.
+func scalarValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.Atoi(s); err == nil && strconv.Itoa(i) == s {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strings.ContainsAny(s, "0123456789") && strconv.FormatFloat(f, 'f', -1, 64) == s {
+		return f
+	}
+	return s
+}
+
+// splitLines splits the data into lines.
+func splitLines(data []byte) []string {
+	return strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+}
+
+// hasContinuation checks if a line ends with a backslash that is not escaped.
+func hasContinuation(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// PrefixValueKey is the key of the value of a key that is also the prefix of other keys in INI and properties
+// files. For example, "a=1" and "a.b=2" in a properties file are loaded as {"a": {"#value": 1, "b": 2}}.
+const PrefixValueKey = "#value"
+
+// childMap returns the map located by the keys in the given map, creating the missing maps along the way.
+func childMap(m map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for i, key := range keys {
+		child, ok := m[key]
+		if !ok {
+			child = map[string]interface{}{}
+			m[key] = child
+		}
+		if m, ok = child.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%q is not a map", PathOf(keys[:i+1]...))
+		}
+	}
+	return m, nil
+}
+
+// prefixMap returns the map located by the keys in the given map in the same way as childMap(), except that
+// a value located by the keys is moved into a new map and keyed by PrefixValueKey.
+func prefixMap(m map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for i, key := range keys {
+		child, ok := m[key]
+		switch child.(type) {
+		case map[string]interface{}:
+		case []interface{}:
+			return nil, fmt.Errorf("%q is an array and cannot contain keys", PathOf(keys[:i+1]...))
+		default:
+			if ok {
+				child = map[string]interface{}{PrefixValueKey: child}
+			} else {
+				child = map[string]interface{}{}
+			}
+			m[key] = child
+		}
+		m = child.(map[string]interface{})
+	}
+	return m, nil
+}
+
+// setValue sets the value located by the keys in the given map. If isArray is true,
+// the value is appended to the array located by the keys. The path of the value is returned.
+// If the keys locate a map, the value is keyed by PrefixValueKey in the map.
+func setValue(m map[string]interface{}, keys []string, value interface{}, isArray bool) (string, error) {
+	m, err := prefixMap(m, keys[:len(keys)-1])
+	if err != nil {
+		return "", err
+	}
+	path := PathOf(keys...)
+	key := keys[len(keys)-1]
+	if child, ok := m[key].(map[string]interface{}); ok {
+		// the key is also the prefix of other keys
+		if isArray {
+			return "", fmt.Errorf("%q is a map and cannot be appended with a value", path)
+		}
+		child[PrefixValueKey] = value
+		return joinPath(path, escapeKey(PrefixValueKey)), nil
+	}
+	switch old := m[key].(type) {
+	case []interface{}:
+		if isArray {
+			path = joinPath(path, strconv.Itoa(len(old)))
+			value = append(old, value)
+		}
+	default:
+		if isArray {
+			path = joinPath(path, "0")
+			value = []interface{}{value}
+		}
+	}
+	m[key] = value
+	return path, nil
+}
+
+// indexArrays converts the maps nested in the given map whose keys are exactly the indexes from "0" to "n-1"
+// into arrays, so that indexed keys such as "A7.0" and "A7.1" populate an array. The paths of the values
+// are not changed by the conversion. The given map itself is kept as a map.
+func indexArrays(m map[string]interface{}) {
+	for key, value := range m {
+		m[key] = indexArray(value)
+	}
+}
+
+// indexArray converts the value into an array if it is a map keyed by indexes as described in indexArrays().
+func indexArray(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	indexArrays(m)
+	if len(m) == 0 {
+		return m
+	}
+	s := make([]interface{}, len(m))
+	for i := range s {
+		e, ok := m[strconv.Itoa(i)]
+		if !ok {
+			return m
+		}
+		s[i] = e
+	}
+	return s
+}
+
+// errorAtLine returns a ParseError describing a problem found at the given line.
+func errorAtLine(line int, format string, args ...interface{}) error {
+	return &ParseError{Position{Line: line}, fmt.Sprintf(format, args...)}
+}
+
+// indentOf returns the number of the leading characters of a line that are in the cutset.
+func indentOf(line, cutset string) int {
+	return len(line) - len(strings.TrimLeft(line, cutset))
+}
+
+// populate populates the parsed configuration data into the given variable.
+func populate(data interface{}, v interface{}) error {
+	if p, ok := data.(*interface{}); ok {
+		*p = v
+		return nil
+	}
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, data)
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalINI(t *testing.T) {
+	tests := []struct {
+		ini      string
+		expected string
+	}{
+		{"", `{}`},
+		{"a = 1\nb: x\nc =\n", `{"a":1,"b":"x","c":""}`},
+		{"; comment\n# comment\n\na = 1", `{"a":1}`},
+		{"[s1]\na = 1\n[s2.t]\nb = 2\n[s1]\nc = 3", `{"s1":{"a":1,"c":3},"s2":{"t":{"b":2}}}`},
+		{"a.b = 1\n[s]\nc.d = 2", `{"a":{"b":1},"s":{"c":{"d":2}}}`},
+		{`[hosts.example\.com]` + "\nport = 80", `{"hosts":{"example.com":{"port":80}}}`},
+		{"a = x ; comment\nb = x;y\nc = x \\; y\nd = # comment", `{"a":"x","b":"x;y","c":"x ; y","d":""}`},
+		{`a = "x ; y" ; comment` + "\n" + `b = "1\t2\"3"` + "\nc = 'x\\ty'", `{"a":"x ; y","b":"1\t2\"3","c":"x\\ty"}`},
+		{"a = \"1\"\nb = 1.50\nc = 0123\nd = TRUE\ne = -2.5\nf = nan", `{"a":"1","b":"1.50","c":"0123","d":"TRUE","e":-2.5,"f":"nan"}`},
+		{"a = C:\\dir\\file", `{"a":"C:\\dir\\file"}`},
+		{"a = one \\\n  two \\\n  three\nb = 1", `{"a":"one two three","b":1}`},
+		{"a = x\\\\\nb = 1", `{"a":"x\\","b":1}`},
+		{"a[] = 1\na[] = x\n[s]\nb[] = 2", `{"a":[1,"x"],"s":{"b":[2]}}`},
+		{"a = 1\r\n[s]\r\nb = 2\r\n", `{"a":1,"s":{"b":2}}`},
+		{"a = 1\n[a]\nb = 2", `{"a":{"#value":1,"b":2}}`},
+		{"a.b = 1\na = 2", `{"a":{"#value":2,"b":1}}`},
+		{"a.1 = y\na.0 = x\n[b.0]\nc = 1\n[d]\n0 = 1\n2 = 2", `{"a":["x","y"],"b":[{"c":1}],"d":{"0":1,"2":2}}`},
+	}
+	for _, test := range tests {
+		var data interface{}
+		if err := unmarshalINI([]byte(test.ini), &data); err != nil {
+			t.Errorf("unmarshalINI(%q): %v", test.ini, err)
+			continue
+		}
+		if s, _ := json.Marshal(data); string(s) != test.expected {
+			t.Errorf("unmarshalINI(%q) = %v, expected %v", test.ini, string(s), test.expected)
+		}
+	}
+
+	errors := []struct {
+		ini      string
+		expected string
+	}{
+		{"a", `1: missing '=' after the key "a"`},
+		{"\n[s", "2: unterminated section name"},
+		{"[]", "1: empty section name"},
+		{"[s] x", `1: unexpected "x" after the section name`},
+		{"= 1", "1: missing the key"},
+		{`a = "x`, "1: unterminated quoted value"},
+		{`a = "x" y`, `1: unexpected "y" after the quoted value`},
+		{"a[] = 1\n[a]", `2: "a" is an array and cannot contain keys`},
+		{"a.b = 1\na[] = 2", `2: "a" is a map and cannot be appended with a value`},
+	}
+	for _, test := range errors {
+		var data interface{}
+		if err := unmarshalINI([]byte(test.ini), &data); err == nil || err.Error() != test.expected {
+			t.Errorf("unmarshalINI(%q) error = %v, expected %v", test.ini, err, test.expected)
+		}
+	}
+
+	var s struct {
+		A int
+		S struct{ B string }
+	}
+	if err := unmarshalINI([]byte("A = 1\n[S]\nB = x"), &s); err != nil || s.A != 1 || s.S.B != "x" {
+		t.Errorf("unmarshalINI() into a struct = %+v, %v", s, err)
+	}
+}
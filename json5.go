@@ -0,0 +1,392 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// unmarshalJSON parses the given JSON data and populates it into the given variable.
+//
+// Besides standard JSON, the data may use the JSON5 extensions, including comments, trailing commas,
+// unquoted object keys, single-quoted strings, multi-line strings, hexadecimal numbers, numbers with
+// leading or trailing decimal points or a plus sign, Infinity, and NaN. As with encoding/json,
+// objects are populated as map[string]interface{} and numbers as float64.
+//
+// Syntax errors are returned as ParseError with the line and column in the given data where the problem is found.
+// An error is also returned if objects and arrays are nested more than 1000 levels deep.
+func unmarshalJSON(bytes []byte, data interface{}) error {
+	value, _, err := parseJSON(bytes)
+	if err != nil {
+		return err
+	}
+	return populate(data, value)
+}
+
+// parseJSON parses the given JSON data as described in unmarshalJSON() and returns the parsed value
+// together with the positions of the values, indexed by their paths.
+func parseJSON(bytes []byte) (interface{}, map[string]Position, error) {
+	p := &json5Parser{s: string(bytes), offsets: map[string]int{}}
+	p.skipSpaces()
+	value, err := p.parseValue("")
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.skipSpaces(); p.i < len(p.s) {
+		return nil, nil, p.errorf("unexpected character %q after the top-level value", p.peekRune())
+	}
+	return value, offsetPositions(p.s, p.offsets), nil
+}
+
+// json5Parser parses JSON5 data.
+type json5Parser struct {
+	s       string         // the data
+	i       int            // the current position
+	depth   int            // the number of objects and arrays being parsed
+	offsets map[string]int // the offsets of the values parsed so far, indexed by their paths
+}
+
+// maxJSON5Depth is the maximum number of nested objects and arrays allowed in JSON data.
+const maxJSON5Depth = 1000
+
+// errorf returns an error describing a problem found at the current position.
+func (p *json5Parser) errorf(format string, args ...interface{}) error {
+	return errorAt(p.s, p.i, format, args...)
+}
+
+// errorAt returns a ParseError describing a problem found at the given byte offset of the data.
+func errorAt(s string, i int, format string, args ...interface{}) error {
+	return &ParseError{positionAt(s, i), fmt.Sprintf(format, args...)}
+}
+
+// peekRune returns the rune at the current position.
+func (p *json5Parser) peekRune() rune {
+	r, _ := utf8.DecodeRuneInString(p.s[p.i:])
+	return r
+}
+
+// parseValue parses a JSON5 value located at the path in the data, starting from the current position.
+func (p *json5Parser) parseValue(path string) (interface{}, error) {
+	if p.i == len(p.s) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	p.offsets[path] = p.i
+	switch c := p.s[p.i]; {
+	case c == '{' || c == '[':
+		if p.depth == maxJSON5Depth {
+			return nil, p.errorf("exceeded the maximum nesting depth of %v", maxJSON5Depth)
+		}
+		p.depth++
+		defer func() {
+			p.depth--
+		}()
+		if c == '{' {
+			return p.parseObject(path)
+		}
+		return p.parseArray(path)
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9' || c == 'I' || c == 'N':
+		return p.parseNumber()
+	}
+
+	start := p.i
+	switch name := p.parseIdentifier(); name {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		return nil, p.errorf("unexpected character %q", p.peekRune())
+	default:
+		p.i = start
+		return nil, p.errorf("unexpected identifier %q", name)
+	}
+}
+
+// parseObject parses an object located at the path.
+func (p *json5Parser) parseObject(path string) (interface{}, error) {
+	object := map[string]interface{}{}
+	for p.i++; ; {
+		p.skipSpaces()
+		if p.i == len(p.s) {
+			return nil, p.errorf("unexpected end of input, expected '}'")
+		}
+		if p.s[p.i] == '}' {
+			p.i++
+			return object, nil
+		}
+
+		var key string
+		if c := p.s[p.i]; c == '"' || c == '\'' {
+			var err error
+			if key, err = p.parseString(); err != nil {
+				return nil, err
+			}
+		} else if key = p.parseIdentifier(); key == "" {
+			return nil, p.errorf("unexpected character %q, expected an object key", p.peekRune())
+		}
+		p.skipSpaces()
+		if p.i == len(p.s) || p.s[p.i] != ':' {
+			return nil, p.errorf("expected ':' after the object key %q", key)
+		}
+		p.i++
+		p.skipSpaces()
+		value, err := p.parseValue(joinPath(path, key))
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+
+		p.skipSpaces()
+		if p.i < len(p.s) && p.s[p.i] == ',' {
+			p.i++
+		} else if p.i == len(p.s) {
+			return nil, p.errorf("unexpected end of input, expected '}'")
+		} else if p.s[p.i] != '}' {
+			return nil, p.errorf("unexpected character %q, expected ',' or '}'", p.peekRune())
+		}
+	}
+}
+
+// parseArray parses an array located at the path.
+func (p *json5Parser) parseArray(path string) (interface{}, error) {
+	array := []interface{}{}
+	for p.i++; ; {
+		p.skipSpaces()
+		if p.i == len(p.s) {
+			return nil, p.errorf("unexpected end of input, expected ']'")
+		}
+		if p.s[p.i] == ']' {
+			p.i++
+			return array, nil
+		}
+		value, err := p.parseValue(joinPath(path, strconv.Itoa(len(array))))
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+
+		p.skipSpaces()
+		if p.i < len(p.s) && p.s[p.i] == ',' {
+			p.i++
+		} else if p.i == len(p.s) {
+			return nil, p.errorf("unexpected end of input, expected ']'")
+		} else if p.s[p.i] != ']' {
+			return nil, p.errorf("unexpected character %q, expected ',' or ']'", p.peekRune())
+		}
+	}
+}
+
+// parseString parses a string enclosed in single or double quotes.
+func (p *json5Parser) parseString() (string, error) {
+	quote := p.s[p.i]
+	var buf bytes.Buffer
+	for p.i++; p.i < len(p.s); {
+		c := p.s[p.i]
+		switch {
+		case c == quote:
+			p.i++
+			return buf.String(), nil
+		case c == '\n' || c == '\r':
+			return "", p.errorf("unterminated string")
+		case c == '\\':
+			if err := p.parseEscape(&buf); err != nil {
+				return "", err
+			}
+		default:
+			buf.WriteByte(c)
+			p.i++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseEscape parses an escape sequence in a string and writes the character it represents to the buffer.
+func (p *json5Parser) parseEscape(buf *bytes.Buffer) error {
+	start := p.i
+	if p.i++; p.i == len(p.s) {
+		return p.errorf("unterminated string")
+	}
+	switch c := p.s[p.i]; c {
+	case 'b':
+		buf.WriteByte('\b')
+	case 'f':
+		buf.WriteByte('\f')
+	case 'n':
+		buf.WriteByte('\n')
+	case 'r':
+		buf.WriteByte('\r')
+	case 't':
+		buf.WriteByte('\t')
+	case 'v':
+		buf.WriteByte('\v')
+	case '0':
+		if p.i+1 < len(p.s) && p.s[p.i+1] >= '0' && p.s[p.i+1] <= '9' {
+			p.i = start
+			return p.errorf("invalid escape sequence \\0%c", p.s[start+2])
+		}
+		buf.WriteByte(0)
+	case '\n':
+		// a line continuation
+	case '\r':
+		// a line continuation
+		if strings.HasPrefix(p.s[p.i+1:], "\n") {
+			p.i++
+		}
+	case 'x', 'u':
+		n := 2
+		if c == 'u' {
+			n = 4
+		}
+		r, ok := p.parseHex(p.i+1, n)
+		if !ok {
+			p.i = start
+			return p.errorf("invalid escape sequence %q", p.s[start:p.i+2])
+		}
+		p.i += n
+		if utf16.IsSurrogate(r) && strings.HasPrefix(p.s[p.i+1:], `\u`) {
+			if r2, ok := p.parseHex(p.i+3, 4); ok {
+				if d := utf16.DecodeRune(r, r2); d != unicode.ReplacementChar {
+					r = d
+					p.i += 6
+				}
+			}
+		}
+		buf.WriteRune(r)
+	default:
+		if c >= '1' && c <= '9' {
+			p.i = start
+			return p.errorf("invalid escape sequence \\%c", c)
+		}
+		// a line continuation if the character is a line or paragraph separator
+		r, size := utf8.DecodeRuneInString(p.s[p.i:])
+		if r != '\u2028' && r != '\u2029' {
+			buf.WriteRune(r)
+		}
+		p.i += size - 1
+	}
+	p.i++
+	return nil
+}
+
+// parseHex parses n hexadecimal digits at the given position.
+func (p *json5Parser) parseHex(i, n int) (rune, bool) {
+	if i+n > len(p.s) {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(p.s[i:i+n], 16, 32)
+	return rune(v), err == nil
+}
+
+// parseNumber parses a number, which may be hexadecimal, Infinity, or NaN.
+func (p *json5Parser) parseNumber() (interface{}, error) {
+	start := p.i
+	sign := 1.0
+	if c := p.s[p.i]; c == '+' || c == '-' {
+		if c == '-' {
+			sign = -1
+		}
+		p.i++
+	}
+	switch s := p.s[p.i:]; {
+	case strings.HasPrefix(s, "Infinity"):
+		p.i += len("Infinity")
+		return math.Inf(int(sign)), nil
+	case strings.HasPrefix(s, "NaN"):
+		p.i += len("NaN")
+		return math.NaN(), nil
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		p.i += 2
+		digits := p.i
+		for p.i < len(p.s) && strings.IndexByte("0123456789abcdefABCDEF", p.s[p.i]) >= 0 {
+			p.i++
+		}
+		v, err := strconv.ParseUint(p.s[digits:p.i], 16, 64)
+		if err != nil {
+			p.i = start
+			return nil, p.errorf("invalid number %q", p.s[start:digits])
+		}
+		return sign * float64(v), nil
+	}
+
+	digits := 0
+	for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+		p.i++
+		digits++
+	}
+	if p.i < len(p.s) && p.s[p.i] == '.' {
+		for p.i++; p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9'; p.i++ {
+			digits++
+		}
+	}
+	if digits > 0 && p.i < len(p.s) && (p.s[p.i] == 'e' || p.s[p.i] == 'E') {
+		p.i++
+		if p.i < len(p.s) && (p.s[p.i] == '+' || p.s[p.i] == '-') {
+			p.i++
+		}
+		for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+			p.i++
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimPrefix(p.s[start:p.i], "+"), 64)
+	if digits == 0 || err != nil {
+		s := p.s[start:p.i]
+		p.i = start
+		if s == "" {
+			return nil, p.errorf("unexpected character %q", p.peekRune())
+		}
+		return nil, p.errorf("invalid number %q", s)
+	}
+	return v, nil
+}
+
+// parseIdentifier parses an identifier used as an unquoted object key or a literal name.
+// An empty string is returned if there is no identifier at the current position.
+func (p *json5Parser) parseIdentifier() string {
+	start := p.i
+	for p.i < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.i:])
+		if r == '$' || r == '_' || unicode.IsLetter(r) || p.i > start && (unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r) || unicode.Is(unicode.Pc, r)) {
+			p.i += size
+		} else {
+			break
+		}
+	}
+	return p.s[start:p.i]
+}
+
+// skipSpaces skips whitespace and comments.
+func (p *json5Parser) skipSpaces() {
+	for p.i < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.i:])
+		switch {
+		case r == '\ufeff' || unicode.IsSpace(r) || r == '\u2028' || r == '\u2029':
+			p.i += size
+		case strings.HasPrefix(p.s[p.i:], "//"):
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+		case strings.HasPrefix(p.s[p.i:], "/*"):
+			end := strings.Index(p.s[p.i+2:], "*/")
+			if end < 0 {
+				// leave the unterminated comment to be reported as an unexpected character
+				return
+			}
+			p.i += end + 4
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json     string
+		expected string
+	}{
+		{`{"a": 1, "b": [true, false, null], "c": {"d": "x"}}`, `{"a":1,"b":[true,false,null],"c":{"d":"x"}}`},
+		{`[1, "a"]`, `[1,"a"]`},
+		{` "a" `, `"a"`},
+		{"// comment\n{\n  /* comment */ \"a\": 1, // comment\n  \"b\": /* x */ 2\n}", `{"a":1,"b":2}`},
+		{`{"a": [1, 2,], "b": {"c": 3,},}`, `{"a":[1,2],"b":{"c":3}}`},
+		{`{a: 1, $b_2: 2, ünï: 3, null: 4}`, `{"$b_2":2,"a":1,"null":4,"ünï":3}`},
+		{`{'a': 'x"y', "b": "x'y", c: 'it\'s'}`, `{"a":"x\"y","b":"x'y","c":"it's"}`},
+		{`["\n\r\t\v\0\\\/\"\'\a", "\x41\u00e9\ud83d\ude00"]`, `["\n\r\t\u000b\u0000\\/\"'a","Aé😀"]`},
+		{"['line1 \\\nline2', 'x\\\r\ny']", `["line1 line2","xy"]`},
+		{`[0x1F, -0xa, .5, 5., +1, -1.5e2, 1E-2, 0]`, `[31,-10,0.5,5,1,-150,0.01,0]`},
+		{"\ufeff{\"a\": 1}\n", `{"a":1}`},
+		{`{"a": 1, "a": 2}`, `{"a":2}`},
+	}
+	for _, test := range tests {
+		var data interface{}
+		if err := unmarshalJSON([]byte(test.json), &data); err != nil {
+			t.Errorf("unmarshalJSON(%q): %v", test.json, err)
+			continue
+		}
+		if s, _ := json.Marshal(data); string(s) != test.expected {
+			t.Errorf("unmarshalJSON(%q) = %v, expected %v", test.json, string(s), test.expected)
+		}
+	}
+
+	var data interface{}
+	if err := unmarshalJSON([]byte(`[Infinity, -Infinity, NaN]`), &data); err != nil {
+		t.Errorf("unmarshalJSON(): %v", err)
+	} else if a := data.([]interface{}); !math.IsInf(a[0].(float64), 1) || !math.IsInf(a[1].(float64), -1) || !math.IsNaN(a[2].(float64)) {
+		t.Errorf("unmarshalJSON() = %v, expected [+Inf -Inf NaN]", a)
+	}
+
+	if err := unmarshalJSON([]byte(`'\b\f'`), &data); err != nil || data != "\b\f" {
+		t.Errorf("unmarshalJSON() = %q, %v, expected %q", data, err, "\b\f")
+	}
+
+	var s struct {
+		A int
+		B []string
+	}
+	if err := unmarshalJSON([]byte(`{A: 1, B: ['x',],}`), &s); err != nil || s.A != 1 || len(s.B) != 1 || s.B[0] != "x" {
+		t.Errorf("unmarshalJSON() into a struct = %+v, %v", s, err)
+	}
+
+	errors := []struct {
+		json     string
+		expected string
+	}{
+		{``, "1:1: unexpected end of input"},
+		{`{`, "1:2: unexpected end of input, expected '}'"},
+		{"{\n  \"a\": 1\n  \"b\": 2\n}", `3:3: unexpected character '"', expected ',' or '}'`},
+		{"{\n  \"a\" 1\n}", `2:7: expected ':' after the object key "a"`},
+		{"{\n  \"é\": x\n}", `2:8: unexpected identifier "x"`},
+		{`[1 2]`, `1:4: unexpected character '2', expected ',' or ']'`},
+		{`[1,`, `1:4: unexpected end of input, expected ']'`},
+		{`[,]`, `1:2: unexpected character ','`},
+		{`{-: 1}`, `1:2: unexpected character '-', expected an object key`},
+		{"[\"a\nb\"]", "1:4: unterminated string"},
+		{`["a`, "1:4: unterminated string"},
+		{`["\x4"]`, `1:3: invalid escape sequence "\\x"`},
+		{`["\1"]`, `1:3: invalid escape sequence \1`},
+		{`[1.2.3]`, `1:5: unexpected character '.', expected ',' or ']'`},
+		{`[-]`, `1:2: invalid number "-"`},
+		{`[0x]`, `1:2: invalid number "0x"`},
+		{`[1e]`, `1:2: invalid number "1e"`},
+		{`{} x`, `1:4: unexpected character 'x' after the top-level value`},
+		{`{} /* x`, `1:4: unexpected character '/' after the top-level value`},
+		{strings.Repeat(`{"a":`, 1000) + `[1]` + strings.Repeat(`}`, 1000), `1:5001: exceeded the maximum nesting depth of 1000`},
+		{strings.Repeat(`[`, 100000), `1:1001: exceeded the maximum nesting depth of 1000`},
+	}
+	for _, test := range errors {
+		var data interface{}
+		if err := unmarshalJSON([]byte(test.json), &data); err == nil || err.Error() != test.expected {
+			t.Errorf("unmarshalJSON(%.40q) error = %v, expected %v", test.json, err, test.expected)
+		}
+	}
+
+	deep := strings.Repeat(`[`, 1000) + strings.Repeat(`]`, 1000)
+	if err := unmarshalJSON([]byte(deep), &data); err != nil {
+		t.Errorf("unmarshalJSON() with the maximum nesting depth: %v", err)
+	}
+}
+
+func TestLoadJSON5(t *testing.T) {
+	c := New()
+	if err := c.Load("testdata/c1.json5"); err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	s, _ := json.Marshal(c.Data())
+	expected := `{"A1":"a1","A2":2,"A3":true,"A4":2.13,"A5":null,"A6":{"B1":"b1","B2":{"C1":"c1"}},"A7":["d1","d2"]}`
+	if string(s) != expected {
+		t.Errorf("Load() = %v, expected %v", string(s), expected)
+	}
+
+	if err := c.LoadJSON([]byte("{\n  a: 1,\n  b: 'x' 'y'\n}")); err == nil || err.Error() != `3:10: unexpected character '\'', expected ',' or '}'` {
+		t.Errorf("LoadJSON() error = %v", err)
+	}
+}
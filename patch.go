@@ -0,0 +1,376 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchError describes a JSON Patch operation that cannot be applied.
+type PatchError struct {
+	Index   int    // the index of the operation in the patch
+	Op      string // the name of the operation
+	Path    string // the JSON Pointer that the operation refers to
+	Message string // the detailed error message
+}
+
+// Error returns the error message represented by PatchError
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %v (%q on %q) failed: %v", e.Index, e.Op, e.Path, e.Message)
+}
+
+// patchOperation represents a single operation in a JSON Patch document.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ApplyPatch modifies the configuration by applying the given JSON Patch document (RFC 6902).
+//
+// The patch is a JSON array of operations, such as:
+//
+//	[
+//		{"op": "replace", "path": "/DB/Host", "value": "db.example.com"},
+//		{"op": "add", "path": "/Servers/-", "value": {"Port": 8080}},
+//		{"op": "remove", "path": "/Debug"}
+//	]
+//
+// The "add", "remove", "replace", "move", "copy", and "test" operations are supported.
+// A JSON Pointer such as "/DB/Host" refers to the same configuration value as the path "DB.Host" does in Get().
+//
+// The patch is applied atomically: if any operation fails, a *PatchError describing the failed
+// operation will be returned and the configuration will remain unchanged.
+func (c *Config) ApplyPatch(patch []byte) error {
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return err
+	}
+
+	data := copyValue(c.data)
+	if !data.IsValid() {
+		data = reflect.ValueOf(make(map[string]interface{}))
+	}
+	for i, op := range ops {
+		var err error
+		if data, err = applyOperation(data, op); err != nil {
+			path := ""
+			if op.Path != nil {
+				path = *op.Path
+			}
+			return &PatchError{i, op.Op, path, err.Error()}
+		}
+	}
+	c.recordChanges(c.data, data, "ApplyPatch")
+	c.data = data
+	return nil
+}
+
+// ApplyMergePatch modifies the configuration by applying the given JSON Merge Patch document (RFC 7386).
+//
+// A merge patch is merged with the configuration according to the rules described in SetData(),
+// except that a null value in the patch removes the corresponding key from the configuration.
+// If the patch is not a JSON object, it will replace the whole configuration.
+//
+// The patch is applied atomically: if it cannot be applied, an error will be returned
+// and the configuration will remain unchanged.
+func (c *Config) ApplyMergePatch(patch []byte) error {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return err
+	}
+	data, err := mergePatch(copyValue(c.data), reflect.ValueOf(p), "")
+	if err != nil {
+		return err
+	}
+	c.recordChanges(c.data, data, "ApplyMergePatch")
+	c.data = data
+	return nil
+}
+
+// mergePatch applies a merge patch to the target value and returns the patched value.
+func mergePatch(target, patch reflect.Value, path string) (reflect.Value, error) {
+	if patch.Kind() != reflect.Map {
+		return patch, nil
+	}
+	if target.Kind() != reflect.Map {
+		target = reflect.ValueOf(make(map[string]interface{}))
+	}
+	for _, key := range patch.MapKeys() {
+		name := key.String()
+		e := mapIndex(patch, key)
+		if !e.IsValid() {
+			if err := setElement(target, name, nil); err != nil {
+				return target, &ConfigPathError{Path: joinPath(path, name), Message: err.Error()}
+			}
+			continue
+		}
+		v, err := mergePatch(getElement(target, name), e, joinPath(path, name))
+		if err != nil {
+			return target, err
+		}
+		if err := setElement(target, name, v.Interface()); err != nil {
+			return target, &ConfigPathError{Path: joinPath(path, name), Message: err.Error()}
+		}
+	}
+	return target, nil
+}
+
+// applyOperation applies a single JSON Patch operation to the data and returns the patched data.
+func applyOperation(data reflect.Value, op patchOperation) (reflect.Value, error) {
+	if op.Path == nil {
+		return data, fmt.Errorf("missing the path member")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return data, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return data, fmt.Errorf("missing the value member")
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return data, err
+		}
+		switch op.Op {
+		case "add":
+			return addValue(data, path, reflect.ValueOf(value))
+		case "replace":
+			if data, err = removeValue(data, path); err != nil {
+				return data, err
+			}
+			return addValue(data, path, reflect.ValueOf(value))
+		}
+		if v, err := pointerValue(data, path); err != nil {
+			return data, err
+		} else if !equalValues(v, reflect.ValueOf(value)) {
+			return data, fmt.Errorf("the value %v is not equal to %v", valueInterface(v), value)
+		}
+		return data, nil
+	case "remove":
+		return removeValue(data, path)
+	case "move", "copy":
+		if op.From == nil {
+			return data, fmt.Errorf("missing the from member")
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return data, err
+		}
+		v, err := pointerValue(data, from)
+		if err != nil {
+			return data, err
+		}
+		if op.Op == "copy" {
+			return addValue(data, path, copyValue(v))
+		}
+		if len(from) < len(path) && isPathPrefix(from, path) {
+			return data, fmt.Errorf("cannot move a value into one of its children")
+		}
+		if data, err = removeValue(data, from); err != nil {
+			return data, err
+		}
+		return addValue(data, path, v)
+	}
+	return data, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// parsePointer parses a JSON Pointer (RFC 6901) into a list of reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%q is not a valid JSON pointer", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// pointerValue returns the value that the parsed JSON pointer refers to.
+func pointerValue(data reflect.Value, path []string) (reflect.Value, error) {
+	for i, token := range path {
+		if !hasElement(data, token) {
+			return data, fmt.Errorf("%q does not exist", "/"+strings.Join(path[:i+1], "/"))
+		}
+		data = getElement(data, token)
+	}
+	return data, nil
+}
+
+// addValue adds a value at the location that the parsed JSON pointer refers to and returns the updated data.
+// If the location is inside an array, the value is inserted at the specified index, while the index "-"
+// appends the value to the array. Otherwise, the value is added to the map, replacing the existing one if any.
+func addValue(data reflect.Value, path []string, value reflect.Value) (reflect.Value, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateContainer(data, path, func(container reflect.Value, token string) (reflect.Value, error) {
+		if container.Kind() == reflect.Map {
+			if err := setElement(container, token, valueInterface(value)); err != nil || value.IsValid() {
+				return container, err
+			}
+			// setElement removes the key for a nil value, while the key should be kept with a null value
+			container.SetMapIndex(reflect.ValueOf(token), reflect.Zero(container.Type().Elem()))
+			return container, nil
+		}
+		if container.Kind() != reflect.Slice {
+			return container, fmt.Errorf("cannot add %q to %v", token, container.Kind())
+		}
+		idx := container.Len()
+		if token != "-" {
+			var err error
+			if idx, err = arrayIndex(token, container.Len()+1); err != nil {
+				return container, err
+			}
+		}
+		s := reflect.MakeSlice(container.Type(), container.Len()+1, container.Len()+1)
+		reflect.Copy(s, container.Slice(0, idx))
+		reflect.Copy(s.Slice(idx+1, s.Len()), container.Slice(idx, container.Len()))
+		return s, setElement(s, strconv.Itoa(idx), valueInterface(value))
+	})
+}
+
+// removeValue removes the value that the parsed JSON pointer refers to and returns the updated data.
+func removeValue(data reflect.Value, path []string) (reflect.Value, error) {
+	if len(path) == 0 {
+		return reflect.Value{}, nil
+	}
+	return updateContainer(data, path, func(container reflect.Value, token string) (reflect.Value, error) {
+		if !hasElement(container, token) {
+			return container, fmt.Errorf("%q does not exist", "/"+strings.Join(path, "/"))
+		}
+		if container.Kind() == reflect.Map {
+			container.SetMapIndex(reflect.ValueOf(token), reflect.Value{})
+			return container, nil
+		}
+		if container.Kind() != reflect.Slice {
+			return container, fmt.Errorf("cannot remove %q from %v", token, container.Kind())
+		}
+		idx, _ := strconv.Atoi(token)
+		s := reflect.MakeSlice(container.Type(), container.Len()-1, container.Len()-1)
+		reflect.Copy(s, container.Slice(0, idx))
+		reflect.Copy(s.Slice(idx, s.Len()), container.Slice(idx+1, container.Len()))
+		return s, nil
+	})
+}
+
+// updateContainer locates the map or array containing the value that the parsed JSON pointer refers to,
+// and replaces the container with the one returned by the update function.
+func updateContainer(data reflect.Value, path []string, update func(container reflect.Value, token string) (reflect.Value, error)) (reflect.Value, error) {
+	if len(path) == 1 {
+		return update(data, path[0])
+	}
+	if !hasElement(data, path[0]) {
+		return data, fmt.Errorf("%q does not exist", "/"+path[0])
+	}
+	e, err := updateContainer(getElement(data, path[0]), path[1:], update)
+	if err != nil {
+		return data, err
+	}
+	return data, setElement(data, path[0], e.Interface())
+}
+
+// hasElement checks if a map, array, or slice has an element at the specified index.
+// Unlike getElement, it reports true for a map element whose value is nil.
+func hasElement(v reflect.Value, p string) bool {
+	switch v.Kind() {
+	case reflect.Map:
+		key := reflect.ValueOf(p)
+		return key.Type().AssignableTo(v.Type().Key()) && v.MapIndex(key).IsValid()
+	case reflect.Array, reflect.Slice:
+		_, err := arrayIndex(p, v.Len())
+		return err == nil
+	}
+	return false
+}
+
+// arrayIndex parses an array index which must be less than the given upper bound.
+func arrayIndex(p string, bound int) (int, error) {
+	idx, err := strconv.Atoi(p)
+	if err != nil || idx < 0 || (len(p) > 1 && p[0] == '0') {
+		return 0, fmt.Errorf("%v is not a valid array index", p)
+	}
+	if idx >= bound {
+		return 0, fmt.Errorf("%v is out of the array index bound", p)
+	}
+	return idx, nil
+}
+
+// isPathPrefix checks if the parsed path p1 is a prefix of the parsed path p2.
+func isPathPrefix(p1, p2 []string) bool {
+	for i := range p1 {
+		if p1[i] != p2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equalValues checks if two configuration values are equal. Maps are compared by their keys
+// regardless of the key types, and numbers are compared by their values regardless of their types.
+func equalValues(v1, v2 reflect.Value) bool {
+	for v1.Kind() == reflect.Interface {
+		v1 = v1.Elem()
+	}
+	for v2.Kind() == reflect.Interface {
+		v2 = v2.Elem()
+	}
+	switch {
+	case !v1.IsValid() || !v2.IsValid():
+		return v1.IsValid() == v2.IsValid()
+	case v1.Kind() == reflect.Map && v2.Kind() == reflect.Map:
+		keys1, keys2 := mapKeys(v1), mapKeys(v2)
+		if len(keys1) != len(keys2) {
+			return false
+		}
+		for name, k1 := range keys1 {
+			k2, ok := keys2[name]
+			if !ok || !equalValues(v1.MapIndex(k1), v2.MapIndex(k2)) {
+				return false
+			}
+		}
+		return true
+	case isArray(v1) && isArray(v2):
+		if v1.Len() != v2.Len() {
+			return false
+		}
+		for i := 0; i < v1.Len(); i++ {
+			if !equalValues(v1.Index(i), v2.Index(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	if f1, ok := numberValue(v1); ok {
+		f2, ok := numberValue(v2)
+		return ok && f1 == f2
+	}
+	return reflect.DeepEqual(v1.Interface(), v2.Interface())
+}
+
+// numberValue returns the value of an integer or floating-point number as a float64.
+func numberValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
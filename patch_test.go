@@ -0,0 +1,155 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	base := `{"a":1,"b":{"c":[1,2,3],"d":"x"},"e/f":2,"g~h":3}`
+	tests := []struct {
+		patch    string
+		expected string
+	}{
+		{`[]`, base},
+		{`[{"op":"add","path":"/z","value":true}]`, `{"a":1,"b":{"c":[1,2,3],"d":"x"},"e/f":2,"g~h":3,"z":true}`},
+		{`[{"op":"add","path":"/a","value":{"x":1}}]`, `{"a":{"x":1},"b":{"c":[1,2,3],"d":"x"},"e/f":2,"g~h":3}`},
+		{`[{"op":"add","path":"/b/c/1","value":9}]`, `{"a":1,"b":{"c":[1,9,2,3],"d":"x"},"e/f":2,"g~h":3}`},
+		{`[{"op":"add","path":"/b/c/-","value":9}]`, `{"a":1,"b":{"c":[1,2,3,9],"d":"x"},"e/f":2,"g~h":3}`},
+		{`[{"op":"add","path":"/b/c/3","value":9}]`, `{"a":1,"b":{"c":[1,2,3,9],"d":"x"},"e/f":2,"g~h":3}`},
+		{`[{"op":"remove","path":"/a"}]`, `{"b":{"c":[1,2,3],"d":"x"},"e/f":2,"g~h":3}`},
+		{`[{"op":"remove","path":"/b/c/0"}]`, `{"a":1,"b":{"c":[2,3],"d":"x"},"e/f":2,"g~h":3}`},
+		{`[{"op":"remove","path":"/e~1f"},{"op":"remove","path":"/g~0h"}]`, `{"a":1,"b":{"c":[1,2,3],"d":"x"}}`},
+		{`[{"op":"replace","path":"/b/d","value":null}]`, `{"a":1,"b":{"c":[1,2,3],"d":null},"e/f":2,"g~h":3}`},
+		{`[{"op":"replace","path":"/b/c/2","value":"y"}]`, `{"a":1,"b":{"c":[1,2,"y"],"d":"x"},"e/f":2,"g~h":3}`},
+		{`[{"op":"replace","path":"","value":{"x":1}}]`, `{"x":1}`},
+		{`[{"op":"move","from":"/b/d","path":"/d"}]`, `{"a":1,"b":{"c":[1,2,3]},"d":"x","e/f":2,"g~h":3}`},
+		{`[{"op":"move","from":"/b/c/0","path":"/b/c/2"}]`, `{"a":1,"b":{"c":[2,3,1],"d":"x"},"e/f":2,"g~h":3}`},
+		{`[{"op":"copy","from":"/b","path":"/x"},{"op":"remove","path":"/x/c"}]`, `{"a":1,"b":{"c":[1,2,3],"d":"x"},"e/f":2,"g~h":3,"x":{"d":"x"}}`},
+		{`[{"op":"test","path":"/b","value":{"d":"x","c":[1,2,3]}},{"op":"remove","path":"/b"}]`, `{"a":1,"e/f":2,"g~h":3}`},
+	}
+	for _, test := range tests {
+		c := New()
+		c.LoadJSON([]byte(base))
+		if err := c.ApplyPatch([]byte(test.patch)); err != nil {
+			t.Errorf("ApplyPatch(%v): %v", test.patch, err)
+			continue
+		}
+		s, _ := json.Marshal(c.Data())
+		if string(s) != test.expected {
+			t.Errorf("ApplyPatch(%v) = %v, expected %v", test.patch, string(s), test.expected)
+		}
+	}
+}
+
+func TestApplyPatchWithError(t *testing.T) {
+	base := `{"a":1,"b":{"c":[1,2,3],"d":"x"}}`
+	tests := []string{
+		`{}`,
+		`[{"op":"unknown","path":"/a"}]`,
+		`[{"op":"add","value":1}]`,
+		`[{"op":"add","path":"/z"}]`,
+		`[{"op":"add","path":"a","value":1}]`,
+		`[{"op":"add","path":"/x/y","value":1}]`,
+		`[{"op":"add","path":"/b/c/4","value":1}]`,
+		`[{"op":"add","path":"/b/c/01","value":1}]`,
+		`[{"op":"add","path":"/a/b","value":1}]`,
+		`[{"op":"remove","path":"/z"}]`,
+		`[{"op":"remove","path":"/b/c/3"}]`,
+		`[{"op":"replace","path":"/z","value":1}]`,
+		`[{"op":"move","path":"/z"}]`,
+		`[{"op":"move","from":"/z","path":"/y"}]`,
+		`[{"op":"move","from":"/b","path":"/b/x"}]`,
+		`[{"op":"copy","from":"/z","path":"/y"}]`,
+		`[{"op":"test","path":"/a","value":2}]`,
+		`[{"op":"test","path":"/z","value":2}]`,
+		`[{"op":"add","path":"/z","value":1},{"op":"remove","path":"/b/d"},{"op":"test","path":"/a","value":"1"}]`,
+	}
+	for _, test := range tests {
+		c := New()
+		c.LoadJSON([]byte(base))
+		if err := c.ApplyPatch([]byte(test)); err == nil {
+			t.Errorf("ApplyPatch(%v) expected an error, got nil", test)
+		}
+		if s, _ := json.Marshal(c.Data()); string(s) != base {
+			t.Errorf("ApplyPatch(%v) changed the configuration to %v", test, string(s))
+		}
+	}
+
+	c := New()
+	err := c.ApplyPatch([]byte(`[{"op":"add","path":"/a","value":1},{"op":"remove","path":"/b"}]`))
+	if e, ok := err.(*PatchError); !ok || e.Index != 1 || e.Op != "remove" || e.Path != "/b" {
+		t.Errorf("ApplyPatch() returned %#v, expected a *PatchError for operation 1", err)
+	}
+}
+
+func TestApplyPatchWithYAML(t *testing.T) {
+	c := New()
+	c.Load("testdata/c1.yaml")
+	patch := `[
+		{"op":"test","path":"/A2","value":2},
+		{"op":"replace","path":"/A6/B2/C1","value":"x"},
+		{"op":"add","path":"/A7/0","value":"d0"}
+	]`
+	if err := c.ApplyPatch([]byte(patch)); err != nil {
+		t.Errorf("ApplyPatch(): %v", err)
+		return
+	}
+	if v := c.Get("A6.B2.C1"); v != "x" {
+		t.Errorf(`Get("A6.B2.C1") = %v, expected %v`, v, "x")
+	}
+	if v := c.Get("A7.0"); v != "d0" {
+		t.Errorf(`Get("A7.0") = %v, expected %v`, v, "d0")
+	}
+	if v := c.Get("A7.2"); v != "d2" {
+		t.Errorf(`Get("A7.2") = %v, expected %v`, v, "d2")
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		base, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`null`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, test := range tests {
+		c := New()
+		c.LoadJSON([]byte(test.base))
+		if err := c.ApplyMergePatch([]byte(test.patch)); err != nil {
+			t.Errorf("ApplyMergePatch(%v): %v", test.patch, err)
+			continue
+		}
+		s, _ := json.Marshal(c.Data())
+		if string(s) != test.expected {
+			t.Errorf("ApplyMergePatch(%v) on %v = %v, expected %v", test.patch, test.base, string(s), test.expected)
+		}
+	}
+
+	c := New()
+	c.SetData(map[string]interface{}{"a": map[string]int{"b": 1}})
+	if err := c.ApplyMergePatch([]byte(`{"a":{"b":2,"c":"x"}}`)); err == nil {
+		t.Errorf("ApplyMergePatch() expected an error, got nil")
+	}
+	if v := c.Get("a.b"); v != 1 {
+		t.Errorf(`Get("a.b") = %v, expected %v`, v, 1)
+	}
+	if err := c.ApplyMergePatch([]byte(`{`)); err == nil {
+		t.Errorf("ApplyMergePatch() expected an error, got nil")
+	}
+}
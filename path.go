@@ -0,0 +1,125 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// PathOf builds a path from the given keys by joining them with dots. Dots, backslashes, and
+// opening brackets in the keys are escaped with backslashes, so that the resulting path can be
+// passed to Get(), Set(), or Configure() to access keys containing such characters.
+//
+// For example, PathOf("Hosts", "example.com", "Port") returns `Hosts.example\.com.Port`.
+func PathOf(parts ...string) string {
+	keys := make([]string, len(parts))
+	for i, part := range parts {
+		keys[i] = escapeKey(part)
+	}
+	return strings.Join(keys, ".")
+}
+
+// keyEscaper escapes the characters that have special meanings in a path.
+var keyEscaper = strings.NewReplacer(`\`, `\\`, `.`, `\.`, `[`, `\[`)
+
+// escapeKey escapes a key so that it can be used as a part of a path.
+func escapeKey(key string) string {
+	return keyEscaper.Replace(key)
+}
+
+// joinPath appends a key to a path, escaping the key if needed.
+func joinPath(path, key string) string {
+	if path == "" {
+		return escapeKey(key)
+	}
+	return path + "." + escapeKey(key)
+}
+
+// keyString returns the string representation of a map key.
+func keyString(key reflect.Value) string {
+	return fmt.Sprint(key.Interface())
+}
+
+// parsePath splits a path into keys.
+//
+// Keys in a path are separated by dots. A key may contain dots if they are escaped with backslashes,
+// such as `Hosts.example\.com.Port`, or if the key is enclosed in brackets and quotes, such as
+// `Hosts["example.com"].Port` or `Hosts['example.com'].Port`. A key may also be enclosed in brackets
+// without quotes, which is mainly used for array indexes, such as `Servers[0].Port`.
+func parsePath(path string) ([]string, error) {
+	keys := []string{}
+	n := len(path)
+	for i := 0; ; {
+		var key string
+		var err error
+		if i < n && path[i] == '[' {
+			key, i, err = parseBracketKey(path, i)
+		} else {
+			key, i, err = parsePlainKey(path, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+
+		if i == n {
+			return keys, nil
+		}
+		switch path[i] {
+		case '.':
+			i++
+		case '[':
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %v", path[i], i)
+		}
+	}
+}
+
+// parsePlainKey parses a key starting at position i that ends with a dot, an opening bracket, or the end of the path.
+// It returns the key and the position following the key.
+func parsePlainKey(path string, i int) (string, int, error) {
+	var key bytes.Buffer
+	for ; i < len(path) && path[i] != '.' && path[i] != '['; i++ {
+		if path[i] == '\\' {
+			if i++; i == len(path) {
+				return "", i, fmt.Errorf("unterminated escape sequence at the end")
+			}
+		}
+		key.WriteByte(path[i])
+	}
+	return key.String(), i, nil
+}
+
+// parseBracketKey parses a key enclosed in brackets starting at position i.
+// It returns the key and the position following the closing bracket.
+func parseBracketKey(path string, i int) (string, int, error) {
+	start := i
+	n := len(path)
+	if i++; i < n && (path[i] == '"' || path[i] == '\'') {
+		quote := path[i]
+		var key bytes.Buffer
+		for i++; i < n && path[i] != quote; i++ {
+			if path[i] == '\\' {
+				i++
+			}
+			if i < n {
+				key.WriteByte(path[i])
+			}
+		}
+		if i+1 >= n || path[i+1] != ']' {
+			return "", i, fmt.Errorf("unterminated quoted key starting at position %v", start)
+		}
+		return key.String(), i + 2, nil
+	}
+
+	end := strings.IndexByte(path[i:], ']')
+	if end < 0 {
+		return "", i, fmt.Errorf("unterminated bracket at position %v", start)
+	}
+	return path[i : i+end], i + end + 1, nil
+}
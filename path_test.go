@@ -0,0 +1,168 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{``, ``},
+		{`a`, `a`},
+		{`a.b.c`, `a|b|c`},
+		{`a..b`, `a||b`},
+		{`a.`, `a|`},
+		{`a\.b.c`, `a.b|c`},
+		{`a\\.b`, `a\|b`},
+		{`a\[0]`, `a[0]`},
+		{`a[0]`, `a|0`},
+		{`a[0][1].b`, `a|0|1|b`},
+		{`a.[0]`, `a|0`},
+		{`[0]`, `0`},
+		{`a["b.c"].d`, `a|b.c|d`},
+		{`a['b.c'].d`, `a|b.c|d`},
+		{`a["b\"c"]`, `a|b"c`},
+		{`a['b"c']`, `a|b"c`},
+		{`a["b]"]`, `a|b]`},
+		{`a[b.c]`, `a|b.c`},
+		{`a[""]`, `a|`},
+		{`a.b]`, `a|b]`},
+	}
+	for _, test := range tests {
+		keys, err := parsePath(test.path)
+		if err != nil {
+			t.Errorf("parsePath(%q): %v", test.path, err)
+			continue
+		}
+		if result := strings.Join(keys, "|"); result != test.expected {
+			t.Errorf("parsePath(%q) = %q, expected %q", test.path, result, test.expected)
+		}
+	}
+
+	errors := []string{
+		`a\`,
+		`a[0`,
+		`a["b"`,
+		`a["b]`,
+		`a["b"]c`,
+		`a[0]b`,
+	}
+	for _, path := range errors {
+		if _, err := parsePath(path); err == nil {
+			t.Errorf("parsePath(%q) expected an error, got nil", path)
+		}
+	}
+}
+
+func TestPathOf(t *testing.T) {
+	tests := []struct {
+		parts    []string
+		expected string
+	}{
+		{[]string{}, ``},
+		{[]string{"a"}, `a`},
+		{[]string{"a", "b", "c"}, `a.b.c`},
+		{[]string{"Hosts", "example.com", "Port"}, `Hosts.example\.com.Port`},
+		{[]string{`a\b`, "[0]"}, `a\\b.\[0]`},
+	}
+	for _, test := range tests {
+		path := PathOf(test.parts...)
+		if path != test.expected {
+			t.Errorf("PathOf(%q) = %q, expected %q", test.parts, path, test.expected)
+		}
+		keys, _ := parsePath(path)
+		if len(test.parts) > 0 && strings.Join(keys, "|") != strings.Join(test.parts, "|") {
+			t.Errorf("parsePath(PathOf(%q)) = %q", test.parts, keys)
+		}
+	}
+}
+
+func TestPathWithDots(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte(`{
+		"Hosts": {
+			"example.com": {"Port": 80},
+			"a[0]": {"Port": 81}
+		},
+		"Versions": {"v1.2": ["x", "y"]}
+	}`))
+
+	// Get
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{`Hosts.example\.com.Port`, 80.0},
+		{`Hosts["example.com"].Port`, 80.0},
+		{`Hosts['example.com']["Port"]`, 80.0},
+		{PathOf("Hosts", "example.com", "Port"), 80.0},
+		{PathOf("Hosts", "a[0]", "Port"), 81.0},
+		{`Versions["v1.2"][1]`, "y"},
+		{`Versions.v1\.2.0`, "x"},
+		{`Hosts.example.com.Port`, nil},
+		{`Hosts["example.com"`, nil},
+	}
+	for _, test := range tests {
+		if v := c.Get(test.path); v != test.expected {
+			t.Errorf("Get(%q) = %v, expected %v", test.path, v, test.expected)
+		}
+	}
+
+	// Set
+	if err := c.Set(`Hosts["example.org"].Port`, 8080); err != nil {
+		t.Errorf("Set(): %v", err)
+	}
+	if v := c.Get(PathOf("Hosts", "example.org", "Port")); v != 8080 {
+		t.Errorf("Get() = %v, expected %v", v, 8080)
+	}
+	if err := c.Set(`Versions.v1\.2[1]`, "z"); err != nil {
+		t.Errorf("Set(): %v", err)
+	}
+	if v := c.Get(`Versions["v1.2"].1`); v != "z" {
+		t.Errorf("Get() = %v, expected %v", v, "z")
+	}
+	if err := c.Set(`Hosts["example.org"`, 1); err == nil {
+		t.Errorf("Set() expected an error, got nil")
+	}
+	err := c.Set(`Hosts["example.org"].Port.X`, 1)
+	if e, ok := err.(*ConfigPathError); !ok || e.Path != `Hosts.example\.org.Port.X` {
+		t.Errorf("Set() returned %v, expected a ConfigPathError for %q", err, `Hosts.example\.org.Port.X`)
+	}
+
+	// Configure
+	var host struct {
+		Port int
+	}
+	if err := c.Configure(&host, `Hosts["example.com"]`); err != nil {
+		t.Errorf("Configure(): %v", err)
+	} else if host.Port != 80 {
+		t.Errorf("host.Port = %v, expected %v", host.Port, 80)
+	}
+	if err := c.Configure(&host, `Hosts["example.com"`); err == nil {
+		t.Errorf("Configure() expected an error, got nil")
+	}
+	var hosts map[string]struct {
+		Port string
+	}
+	err = c.Configure(&hosts, "Hosts")
+	if e, ok := err.(*ConfigValueError); !ok || (e.Path != `Hosts.example\.com.Port` && e.Path != `Hosts.example\.org.Port` && e.Path != `Hosts.a\[0].Port`) {
+		t.Errorf("Configure() returned %v, expected a ConfigValueError with an escaped path", err)
+	}
+
+	// Diff
+	c2 := c.Clone()
+	c2.Set(PathOf("Hosts", "example.com", "Port"), 8000)
+	changes := Diff(c, c2)
+	if len(changes) != 1 || changes[0].Path != `Hosts.example\.com.Port` {
+		t.Errorf("Diff() = %v, expected a change at %q", changes, `Hosts.example\.com.Port`)
+	} else if v := c2.Get(changes[0].Path); v != 8000 {
+		t.Errorf("Get(%q) = %v, expected %v", changes[0].Path, v, 8000)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/xml"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Position describes a location in a configuration file.
+type Position struct {
+	File   string // the file name, empty if the configuration was not loaded from a file
+	Line   int    // the line number, starting from 1; 0 if unknown
+	Column int    // the column number (in characters), starting from 1; 0 if unknown
+}
+
+// IsValid reports whether the position has a line number.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position in the format of "file:line:column". The parts that are unknown are omitted,
+// e.g. "file:line", "line:column", or "file". An empty string is returned if nothing is known about the position.
+func (p Position) String() string {
+	s := p.File
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += strconv.Itoa(p.Line)
+		if p.Column > 0 {
+			s += ":" + strconv.Itoa(p.Column)
+		}
+	}
+	return s
+}
+
+// ParseError describes a problem found when parsing configuration data.
+type ParseError struct {
+	Position Position // where the problem is found
+	Message  string   // the detailed error message
+}
+
+// Error returns the error message represented by ParseError.
+func (e *ParseError) Error() string {
+	if pos := e.Position.String(); pos != "" {
+		return pos + ": " + e.Message
+	}
+	return e.Message
+}
+
+// Position returns the position in the configuration file where the value at the specified path was defined.
+//
+// Positions are recorded for the values loaded by Load(), LoadDotEnv(), and LoadJSON(). The line and column
+// numbers are known for all built-in file formats except TOML, for which only the file name is known, as well as
+// for the files parsed by the functions registered in UnmarshalFuncMap in place of the built-in ones.
+// A zero Position is returned if the value was not loaded from a file or a JSON string, or if it does not exist.
+func (c *Config) Position(path string) Position {
+	parts, err := parsePath(path)
+	if err != nil {
+		return Position{}
+	}
+	return c.positions[c.sourceKey(PathOf(parts...))]
+}
+
+// setPositions records the positions of the values in v, which is merged into the configuration at the path.
+func (c *Config) setPositions(path string, v reflect.Value, positions map[string]Position) {
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if isDeleteMarker(v) {
+		return
+	}
+	if pos, ok := positions[path]; ok {
+		c.positions[c.sourceKey(path)] = pos
+	}
+	eachElement(v, func(key string, e reflect.Value) {
+		c.setPositions(joinPath(path, key), e, positions)
+	})
+}
+
+// valueError returns a ConfigValueError for the value at the path, with the position where the value was defined.
+func (c *Config) valueError(path, message string) error {
+	return &ConfigValueError{Path: path, Message: message, Position: c.positions[c.sourceKey(path)]}
+}
+
+// pathError returns a ConfigPathError for the path, with the position where the value at the path was defined.
+func (c *Config) pathError(path, message string) error {
+	return &ConfigPathError{Path: path, Message: message, Position: c.Position(path)}
+}
+
+// positionParser parses configuration data and returns the parsed value together with the positions of the values,
+// indexed by their paths.
+type positionParser func(bytes []byte) (interface{}, map[string]Position, error)
+
+// documentParser parses configuration data and returns the documents in the data together with the positions of
+// the values in each document. The positions are nil if they are unknown.
+type documentParser func(bytes []byte) ([]interface{}, []map[string]Position, error)
+
+// singleDocument returns a documentParser that parses the data as a single document using the given parser.
+func singleDocument(parser positionParser) documentParser {
+	return func(bytes []byte) ([]interface{}, []map[string]Position, error) {
+		v, positions, err := parser(bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []interface{}{v}, []map[string]Position{positions}, nil
+	}
+}
+
+// builtinParsers lists the parsers of the built-in unmarshal functions registered in UnmarshalFuncMap.
+var builtinParsers = []struct {
+	unmarshal UnmarshalFunc
+	parser    documentParser
+}{
+	{unmarshalYAML, parseYAML},
+	{unmarshalJSON, singleDocument(parseJSON)},
+	{unmarshalINI, singleDocument(parseINI)},
+	{unmarshalProperties, singleDocument(parseProperties)},
+	{unmarshalDotEnv, singleDocument(parseDotEnv(DefaultDotEnvSeparator))},
+	{unmarshalHCL, singleDocument(parseHCL)},
+	{unmarshalXML, singleDocument(parseDefaultXML)},
+}
+
+// parserOf returns the parser used to load configuration data with the unmarshal function, or nil if the function
+// is nil. A built-in unmarshal function is replaced with its parser, which returns the positions of the parsed values
+// and splits YAML data into documents. Any other function is used as it is and returns no positions.
+func parserOf(unmarshal UnmarshalFunc) documentParser {
+	if unmarshal == nil {
+		return nil
+	}
+	for _, p := range builtinParsers {
+		if sameFunc(unmarshal, p.unmarshal) {
+			return p.parser
+		}
+	}
+	return func(bytes []byte) ([]interface{}, []map[string]Position, error) {
+		var data interface{}
+		if err := unmarshal(bytes, &data); err != nil {
+			return nil, nil, err
+		}
+		return []interface{}{data}, []map[string]Position{nil}, nil
+	}
+}
+
+// sameFunc checks if two unmarshal functions are the same top-level function. Functions cannot be compared
+// in Go, so they are compared by their code pointers, which do not identify the closures created by a function.
+func sameFunc(f, g UnmarshalFunc) bool {
+	return reflect.ValueOf(f).Pointer() == reflect.ValueOf(g).Pointer()
+}
+
+// parse parses the configuration data read from the file, if any, using the parser. It returns the documents in
+// the data, at least one, and the positions of the values in each document. The positions are set with the file name,
+// and only the file name is known if the parser does not return the positions. Parsing errors are returned as ParseError.
+func parse(file string, bytes []byte, parser documentParser) ([]interface{}, []map[string]Position, error) {
+	docs, positions, err := parser(bytes)
+	if err != nil {
+		return nil, nil, parseError(file, err)
+	}
+	if len(docs) == 0 {
+		// empty data are loaded as a null value
+		docs, positions = []interface{}{nil}, []map[string]Position{nil}
+	}
+	for i, m := range positions {
+		if m == nil {
+			if file == "" {
+				continue
+			}
+			m = map[string]Position{}
+			walkValue("", reflect.ValueOf(docs[i]), func(path string, v reflect.Value) {
+				m[path] = Position{}
+			})
+			positions[i] = m
+		}
+		for path, pos := range m {
+			pos.File = file
+			m[path] = pos
+		}
+	}
+	return docs, positions, nil
+}
+
+// yamlErrorPattern matches the error messages of the YAML parser that contain a line number.
+var yamlErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (?s:(.*))$`)
+
+// parseError converts an error returned by an unmarshal function into a ParseError with the file name.
+func parseError(file string, err error) error {
+	switch e := err.(type) {
+	case *ParseError:
+		return &ParseError{Position{file, e.Position.Line, e.Position.Column}, e.Message}
+	case *xml.SyntaxError:
+		return &ParseError{Position{File: file, Line: e.Line}, e.Msg}
+	case toml.ParseError:
+		return &ParseError{Position{file, e.Position.Line, e.Position.Col}, e.Message}
+	}
+	if m := yamlErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &ParseError{Position{File: file, Line: line}, m[2]}
+	}
+	return &ParseError{Position{File: file}, err.Error()}
+}
+
+// positionAt returns the position of the byte offset i in the data s.
+func positionAt(s string, i int) Position {
+	line := strings.Count(s[:i], "\n") + 1
+	column := utf8.RuneCountInString(s[strings.LastIndexByte(s[:i], '\n')+1:i]) + 1
+	return Position{Line: line, Column: column}
+}
+
+// offsetPositions converts the byte offsets of the values in the data s into positions.
+func offsetPositions(s string, offsets map[string]int) map[string]Position {
+	lines := []int{0}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	positions := make(map[string]Position, len(offsets))
+	for path, offset := range offsets {
+		n := sort.SearchInts(lines, offset+1) - 1
+		positions[path] = Position{Line: n + 1, Column: utf8.RuneCountInString(s[lines[n]:offset]) + 1}
+	}
+	return positions
+}
@@ -0,0 +1,188 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      Position
+		expected string
+	}{
+		{Position{"app.yaml", 42, 7}, "app.yaml:42:7"},
+		{Position{"app.ini", 3, 0}, "app.ini:3"},
+		{Position{"", 2, 5}, "2:5"},
+		{Position{"app.toml", 0, 0}, "app.toml"},
+		{Position{}, ""},
+	}
+	for _, test := range tests {
+		if s := test.pos.String(); s != test.expected {
+			t.Errorf("%#v.String() = %q, expected %q", test.pos, s, test.expected)
+		}
+	}
+	if (Position{File: "app.toml"}).IsValid() {
+		t.Errorf("IsValid() = true for a position without a line number")
+	}
+}
+
+func TestPosition(t *testing.T) {
+	tests := []struct {
+		file, path string
+		expected   Position
+	}{
+		{"c1.json", "A6.B2.C1", Position{"testdata/c1.json", 13, 13}},
+		{"c1.json", "A7.1", Position{"testdata/c1.json", 17, 11}},
+		{"c1.json", "A6", Position{"testdata/c1.json", 10, 9}},
+		{"c1.yaml", "A6.B2.C1", Position{"testdata/c1.yaml", 10, 9}},
+		{"c1.yaml", "A7[1]", Position{"testdata/c1.yaml", 13, 5}},
+		{"c1.toml", "A6.B2.C1", Position{File: "testdata/c1.toml"}},
+		{"c1.hcl", "A6.B2.C1", Position{"testdata/c1.hcl", 13, 8}},
+		{"c1.hcl", "A7.1", Position{"testdata/c1.hcl", 6, 13}},
+		{"c1.xml", "A6.B2.C1", Position{"testdata/c1.xml", 11, 7}},
+		{"c1.xml", "A7.1", Position{"testdata/c1.xml", 15, 3}},
+		{"c1.ini", "A6.B2.C1", Position{"testdata/c1.ini", 13, 1}},
+		{"c1.ini", "A7.1", Position{"testdata/c1.ini", 7, 1}},
+		{"c1.properties", "A6.B2.C1", Position{"testdata/c1.properties", 7, 1}},
+		{"c1.env", "A6.B2.C1", Position{"testdata/c1.env", 7, 1}},
+		{"c1.json", "A8", Position{}},
+		{"c1.json", "A6[", Position{}},
+	}
+	for _, test := range tests {
+		c := New()
+		if err := c.Load(filepath.Join("testdata", test.file)); err != nil {
+			t.Errorf("Load(%q): %v", test.file, err)
+			continue
+		}
+		if pos := c.Position(test.path); pos != test.expected {
+			t.Errorf("Load(%q), Position(%q) = %v, expected %v", test.file, test.path, pos, test.expected)
+		}
+	}
+
+	// the positions of the values in the YAML documents and the later files take precedence
+	c := New()
+	c.Load("testdata/c1.yaml", "testdata/c2.yaml")
+	c.LoadJSON([]byte("{\n  \"A1\": \"x\"\n}"))
+	if pos := c.Position("A1"); pos != (Position{Line: 2, Column: 9}) {
+		t.Errorf("Position(%q) = %v, expected %v", "A1", pos, "2:9")
+	}
+	if pos := c.Position("A6.B1"); pos.File != "testdata/c1.yaml" {
+		t.Errorf("Position(%q) = %v, expected a position in %v", "A6.B1", pos, "testdata/c1.yaml")
+	}
+	if pos := c.Clone().Position("A6.B1"); pos.File != "testdata/c1.yaml" {
+		t.Errorf("Clone().Position(%q) = %v, expected a position in %v", "A6.B1", pos, "testdata/c1.yaml")
+	}
+
+	// the positions are removed when the values are changed
+	c.Set("A6", map[string]interface{}{"B1": "x"})
+	if pos := c.Position("A6.B1"); pos.IsValid() {
+		t.Errorf("Position(%q) = %v after Set(), expected none", "A6.B1", pos)
+	}
+	c.SetData(map[string]interface{}{"A1": "x"})
+	if pos := c.Position("A1"); pos.IsValid() {
+		t.Errorf("Position(%q) = %v after SetData(), expected none", "A1", pos)
+	}
+}
+
+func TestLoadParseError(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "ozzo-config")
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name, content, expected string
+	}{
+		{"a.json", "{\n  \"a\": 1\n  \"b\": 2\n}", `a.json:3:3: unexpected character '"', expected ',' or '}'`},
+		{"a.yaml", "a: 1\nb: [\n", "a.yaml:2: did not find expected node content"},
+		{"a.yaml", "a: 1\n[x]: 2\n", "a.yaml:2:1: invalid map key: [x]"},
+		{"a.toml", "a = 1\nb = \n", "a.toml:2:5: expected value but found '\\n' instead"},
+		{"a.xml", "<a>\n<b>1</a>", "a.xml:2: element <b> closed by </a>"},
+		{"a.ini", "a = 1\n[b", "a.ini:2: unterminated section name"},
+		{"a.env", "A=1\nB", `a.env:2: missing '=' after the key "B"`},
+		{"a.hcl", "a = 1\nb = var.x", `a.hcl:2:5: unsupported expression "var": only literal values are allowed`},
+	}
+	for _, test := range tests {
+		file := filepath.Join(dir, test.name)
+		ioutil.WriteFile(file, []byte(test.content), 0600)
+		err := New().Load(file)
+		e, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Load(%q) returned %v, expected a ParseError", test.name, err)
+			continue
+		}
+		if e.Position.File != file {
+			t.Errorf("Load(%q) returned an error in %q, expected %q", test.name, e.Position.File, file)
+		}
+		if e.Error() != filepath.Join(dir, test.expected) {
+			t.Errorf("Load(%q) error = %v, expected %v", test.name, e.Error(), filepath.Join(dir, test.expected))
+		}
+	}
+}
+
+func TestConfigureErrorPosition(t *testing.T) {
+	c := New()
+	if err := c.Load("testdata/c1.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		A1, A2 string
+		A3     bool
+		A4     float64
+		A5     interface{}
+		A6     struct {
+			B1 string
+			B2 struct{ C1 int }
+		}
+		A7 []string
+	}
+	err := c.Configure(&v)
+	e, ok := err.(*ConfigValueError)
+	if !ok {
+		t.Fatalf("Configure() returned %v, expected a ConfigValueError", err)
+	}
+	if e.Position != (Position{"testdata/c1.yaml", 10, 9}) {
+		t.Errorf("Configure() returned an error at %v, expected %v", e.Position, "testdata/c1.yaml:10:9")
+	}
+	expected := `testdata/c1.yaml:10:9: "A6.B2.C1" points to an inappropriate configuration value: string cannot be used to configure int`
+	if err.Error() != expected {
+		t.Errorf("Configure() error = %v, expected %v", err, expected)
+	}
+
+	err = c.Set("A6.B1.C1", 1)
+	if e, ok := err.(*ConfigPathError); !ok || e.Position != (Position{"testdata/c1.yaml", 8, 7}) {
+		t.Errorf("Set() returned %#v, expected a ConfigPathError at %v", err, "testdata/c1.yaml:8:7")
+	}
+}
+
+func TestParserOf(t *testing.T) {
+	if parserOf(nil) != nil {
+		t.Errorf("parserOf(nil) is not nil")
+	}
+
+	// a custom unmarshal function is used as it is, and only the file names of the values are known
+	defer func(f UnmarshalFunc) {
+		UnmarshalFuncMap[".xml"] = f
+	}(UnmarshalFuncMap[".xml"])
+	UnmarshalFuncMap[".xml"] = XMLUnmarshaler("@", "#text")
+	c := New()
+	if err := c.Load("testdata/c1.xml"); err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if pos := c.Position("A6.B2.C1"); pos != (Position{File: "testdata/c1.xml"}) {
+		t.Errorf("Position(%q) = %v, expected %v", "A6.B2.C1", pos, "testdata/c1.xml")
+	}
+
+	docs, positions, err := parserOf(unmarshalYAML)([]byte("a: 1\n---\nb: 2"))
+	if err != nil || len(docs) != 2 || positions[1]["b"] != (Position{Line: 3, Column: 4}) {
+		t.Errorf("parserOf(unmarshalYAML) = %v, %v, %v, expected two documents with positions", docs, positions, err)
+	}
+	docs, positions, err = parserOf(XMLUnmarshaler("-", "_text"))([]byte(`<a x="1"/>`))
+	if err != nil || len(docs) != 1 || positions[0] != nil {
+		t.Errorf("parserOf(XMLUnmarshaler()) = %v, %v, %v, expected a document without positions", docs, positions, err)
+	}
+}
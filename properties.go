@@ -0,0 +1,149 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// unmarshalProperties parses the given Java properties data and populates it into the given variable.
+//
+// The data follow the format of java.util.Properties: keys and values are separated by "=", ":",
+// or whitespace; lines starting with "#" or "!" are comments; a line ending with a backslash is continued
+// on the next line; and escape sequences such as "\t", "\n", and "\uXXXX" are supported.
+//
+// Dotted keys become paths, so that "A6.B2.C1=c1" populates the value config["A6"]["B2"]["C1"].
+// Indexed keys such as "A7.0=d1" and "A7.1=d2" populate an array if the indexes start from 0 without gaps.
+// If a key is also the prefix of other keys, its value is keyed by PrefixValueKey in the map of the key.
+// A dot escaped with a backslash is kept in the key. Values that are integers, floats, true, or false
+// are converted into the corresponding types, and the rest are kept as strings.
+func unmarshalProperties(bytes []byte, data interface{}) error {
+	root, _, err := parseProperties(bytes)
+	if err != nil {
+		return err
+	}
+	return populate(data, root)
+}
+
+// parseProperties parses the given Java properties data as described in unmarshalProperties() and returns
+// the parsed value together with the positions of the values, indexed by their paths.
+func parseProperties(bytes []byte) (interface{}, map[string]Position, error) {
+	root := map[string]interface{}{}
+	positions := map[string]Position{}
+	lines := splitLines(bytes)
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		pos := Position{Line: n, Column: indentOf(lines[i], " \t\f") + 1}
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		for hasContinuation(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if hasContinuation(line) {
+			line = line[:len(line)-1]
+		}
+
+		key, value := splitProperty(line)
+		keys := []string{}
+		for _, part := range splitKey(key) {
+			part, err := unescapeProperty(part)
+			if err != nil {
+				return nil, nil, errorAtLine(n, "%v", err)
+			}
+			keys = append(keys, part)
+		}
+		value, err := unescapeProperty(value)
+		if err != nil {
+			return nil, nil, errorAtLine(n, "%v", err)
+		}
+		path, err := setValue(root, keys, scalarValue(value), false)
+		if err != nil {
+			return nil, nil, errorAtLine(n, "%v", err)
+		}
+		positions[path] = pos
+	}
+	indexArrays(root)
+	return root, positions, nil
+}
+
+// splitProperty splits a property line into the key and the value, both of which are not unescaped.
+func splitProperty(line string) (string, string) {
+	i := 0
+	for ; i < len(line); i++ {
+		if c := line[i]; c == '\\' {
+			i++
+		} else if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			break
+		}
+	}
+	if i > len(line) {
+		i = len(line)
+	}
+	key, rest := line[:i], strings.TrimLeft(line[i:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	return key, rest
+}
+
+// splitKey splits a property key by the dots that are not escaped.
+func splitKey(key string) []string {
+	parts := []string{}
+	start := 0
+	for i := 0; i < len(key); i++ {
+		if key[i] == '\\' {
+			i++
+		} else if key[i] == '.' {
+			parts = append(parts, key[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, key[start:])
+}
+
+// unescapeProperty replaces the escape sequences in a property key or value with the characters they represent.
+func unescapeProperty(s string) (string, error) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 't':
+			buf.WriteByte('\t')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\u escape sequence %q", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape sequence %q", s[i-1:i+5])
+			}
+			buf.WriteRune(rune(r))
+			i += 4
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), nil
+}
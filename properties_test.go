@@ -0,0 +1,57 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalProperties(t *testing.T) {
+	tests := []struct {
+		properties string
+		expected   string
+	}{
+		{"", `{}`},
+		{"a=1\nb:x\nc y\nd = z\n  e  :  w  \nf", `{"a":1,"b":"x","c":"y","d":"z","e":"w  ","f":""}`},
+		{"# comment\n! comment\n\n  # comment\na=1", `{"a":1}`},
+		{"a.b=1\na.c.d=true\na.e=x", `{"a":{"b":1,"c":{"d":true},"e":"x"}}`},
+		{"host\\.name=x\nkey\\ with\\ spaces=y\nk\\=v=z", `{"host.name":"x","k=v":"z","key with spaces":"y"}`},
+		{"a=x\\ty\\nz\\\\w\nb=\\u00e9t\\u00E9\nc=\\q", `{"a":"x\ty\nz\\w","b":"été","c":"q"}`},
+		{"a=one, \\\n    two, \\\n    three\nb=1", `{"a":"one, two, three","b":1}`},
+		{"a=x\\\\\nb=1", `{"a":"x\\","b":1}`},
+		{"a=x\\", `{"a":"x"}`},
+		{"a=1.50\nb=0123\nc=2.5\nd=-3", `{"a":"1.50","b":"0123","c":2.5,"d":-3}`},
+		{"a=1\r\nb=2\r\n", `{"a":1,"b":2}`},
+		{"log4j.appender.A1=org.apache.log4j.ConsoleAppender\nlog4j.appender.A1.layout=org.apache.log4j.PatternLayout",
+			`{"log4j":{"appender":{"A1":{"#value":"org.apache.log4j.ConsoleAppender","layout":"org.apache.log4j.PatternLayout"}}}}`},
+		{"a.b=1\na=2", `{"a":{"#value":2,"b":1}}`},
+		{"a.1=y\na.0=x\nb.0.c=1\nd.0=1\nd.2=2\ne.00=1\n0=1", `{"0":1,"a":["x","y"],"b":[{"c":1}],"d":{"0":1,"2":2},"e":{"00":1}}`},
+	}
+	for _, test := range tests {
+		var data interface{}
+		if err := unmarshalProperties([]byte(test.properties), &data); err != nil {
+			t.Errorf("unmarshalProperties(%q): %v", test.properties, err)
+			continue
+		}
+		if s, _ := json.Marshal(data); string(s) != test.expected {
+			t.Errorf("unmarshalProperties(%q) = %v, expected %v", test.properties, string(s), test.expected)
+		}
+	}
+
+	errors := []struct {
+		properties string
+		expected   string
+	}{
+		{"a=\\u12", `1: malformed \u escape sequence "\\u12"`},
+		{"\na=\\uzzzz", `2: malformed \u escape sequence "\\uzzzz"`},
+	}
+	for _, test := range errors {
+		var data interface{}
+		if err := unmarshalProperties([]byte(test.properties), &data); err == nil || err.Error() != test.expected {
+			t.Errorf("unmarshalProperties(%q) error = %v, expected %v", test.properties, err, test.expected)
+		}
+	}
+}
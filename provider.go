@@ -0,0 +1,173 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Scope specifies how long an instance created by a provider registered via RegisterScoped() is reused.
+type Scope int
+
+const (
+	// Transient creates a new instance every time the type is needed.
+	Transient Scope = iota
+	// PerConfigure creates a single instance for a call of Configure(), ConfigureStrict(), or Resolve().
+	PerConfigure
+	// Singleton creates a single instance for the configuration.
+	Singleton
+)
+
+// RegisterScoped associates a type name with a provider in the same way as Register(), and specifies the scope
+// in which an instance created by the provider is reused. Register() registers a provider with the Transient scope.
+//
+// Note that a reused instance is configured again whenever it is created for a configuration map, so it should
+// usually be a pointer when it is shared.
+func (c *Config) RegisterScoped(name string, provider interface{}, scope Scope) error {
+	v := reflect.ValueOf(provider)
+	if v.Kind() != reflect.Func || v.Type().NumOut() != 1 || v.Type().IsVariadic() {
+		return &ProviderError{v}
+	}
+	c.types[name] = v
+	c.scopes[name] = scope
+	c.typeSecrets[name] = c.registeredSecretFields(v.Type().Out(0))
+	c.mu.Lock()
+	delete(c.singletons, name)
+	c.mu.Unlock()
+	return nil
+}
+
+// RegisterInstance registers a value that can be passed to the providers registered via Register() or RegisterScoped()
+// and returned by Resolve(). The value is used for the parameters and targets of the types it can be assigned to,
+// and it replaces the instance of the same type registered earlier.
+func (c *Config) RegisterInstance(instance interface{}) {
+	v := reflect.ValueOf(instance)
+	if v.IsValid() {
+		c.instances[v.Type()] = v
+	}
+}
+
+// Resolve sets the value pointed to by v with an instance of its type.
+//
+// The instance is either an instance registered via RegisterInstance(), or it is created by the only provider
+// registered via Register() or RegisterScoped() whose output can be assigned to the value. The parameters of the
+// provider are resolved in the same way, so the registered types form a simple dependency injection container:
+//
+//	c.RegisterInstance(db)                                  // *sql.DB
+//	c.RegisterScoped("logger", NewLogger, config.Singleton) // func() Logger
+//	c.Register("users", NewUserService)                     // func(db *sql.DB, log Logger) *UserService
+//
+//	var users *UserService
+//	err := c.Resolve(&users)
+//
+// The providers with parameters are also called in the same way when Configure() creates instances by the type names.
+// An error is returned if a type cannot be resolved, if it is resolved ambiguously, or if the providers depend on
+// each other in a cycle.
+func (c *Config) Resolve(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &ConfigTargetError{rv}
+	}
+	instance, err := (&configurer{Config: c}).resolve(rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+	rv.Elem().Set(instance)
+	return nil
+}
+
+// resolve returns an instance of the given type, which is a registered instance, or an instance created by a provider.
+func (c *configurer) resolve(t reflect.Type) (reflect.Value, error) {
+	var instance reflect.Value
+	var candidates []string
+	for it, v := range c.instances {
+		if it.AssignableTo(t) {
+			instance = v
+			candidates = append(candidates, it.String())
+		}
+	}
+	if len(candidates) == 1 {
+		return instance, nil
+	}
+	if len(candidates) > 1 {
+		sort.Strings(candidates)
+		return reflect.Value{}, fmt.Errorf("%v is resolved ambiguously by the instances of %v", t, strings.Join(candidates, ", "))
+	}
+
+	for name, provider := range c.types {
+		if provider.Type().Out(0).AssignableTo(t) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return reflect.Value{}, fmt.Errorf("no instance or provider is registered for %v", t)
+	}
+	if len(candidates) > 1 {
+		sort.Strings(candidates)
+		return reflect.Value{}, fmt.Errorf("%v is resolved ambiguously by the types %v", t, strings.Join(candidates, ", "))
+	}
+	return c.build(candidates[0])
+}
+
+// build returns an instance of the registered type, which is created by the provider if it cannot be reused.
+// The parameters of the provider are resolved by resolve().
+func (c *configurer) build(name string) (reflect.Value, error) {
+	switch c.scopes[name] {
+	case Singleton:
+		c.mu.Lock()
+		instance, ok := c.singletons[name]
+		c.mu.Unlock()
+		if ok {
+			return instance, nil
+		}
+	case PerConfigure:
+		if instance, ok := c.scoped[name]; ok {
+			return instance, nil
+		}
+	}
+
+	for i, n := range c.building {
+		if n == name {
+			cycle := append(append([]string{}, c.building[i:]...), name)
+			return reflect.Value{}, fmt.Errorf("dependency cycle detected: %v", strings.Join(cycle, " -> "))
+		}
+	}
+	c.building = append(c.building, name)
+	defer func() {
+		c.building = c.building[:len(c.building)-1]
+	}()
+
+	provider := c.types[name]
+	args := make([]reflect.Value, provider.Type().NumIn())
+	for i := range args {
+		arg, err := c.resolve(provider.Type().In(i))
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("unable to create %q: %v", name, err)
+		}
+		args[i] = arg
+	}
+	instance := provider.Call(args)[0]
+
+	switch c.scopes[name] {
+	case Singleton:
+		// the provider is called without the lock, so a concurrent call may have created the singleton first
+		c.mu.Lock()
+		if existing, ok := c.singletons[name]; ok {
+			instance = existing
+		} else {
+			c.singletons[name] = instance
+		}
+		c.mu.Unlock()
+	case PerConfigure:
+		if c.scoped == nil {
+			c.scoped = map[string]reflect.Value{}
+		}
+		c.scoped[name] = instance
+	}
+	return instance, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+type testDB struct {
+	DSN string
+}
+
+type testLogger interface {
+	Log(string)
+}
+
+type testLog struct {
+	Prefix string
+	lines  []string
+}
+
+func (l *testLog) Log(s string) {
+	l.lines = append(l.lines, l.Prefix+s)
+}
+
+type testService struct {
+	DB   *testDB
+	Log  testLogger
+	Name string
+}
+
+func (s *testService) Foo() {
+}
+
+func TestResolve(t *testing.T) {
+	c := New()
+	db := &testDB{"mysql://localhost"}
+	c.RegisterInstance(db)
+	count := 0
+	c.RegisterScoped("logger", func() testLogger {
+		count++
+		return &testLog{Prefix: "> "}
+	}, Singleton)
+	c.Register("service", func(db *testDB, log testLogger) *testService {
+		log.Log("created")
+		return &testService{DB: db, Log: log}
+	})
+
+	var s1, s2 *testService
+	if err := c.Resolve(&s1); err != nil {
+		t.Fatalf("Resolve(): %v", err)
+	}
+	if err := c.Resolve(&s2); err != nil {
+		t.Fatalf("Resolve(): %v", err)
+	}
+	if s1 == s2 || s1.DB != db || s1.Log != s2.Log || count != 1 {
+		t.Errorf("Resolve() = %+v, %+v, expected different services sharing the instance and the singleton", s1, s2)
+	}
+	if lines := s1.Log.(*testLog).lines; len(lines) != 2 || lines[0] != "> created" {
+		t.Errorf("the logger got %v, expected two lines", lines)
+	}
+	if err := c.Clone().Resolve(&s2); err != nil || s2.Log == s1.Log || count != 2 {
+		t.Errorf("Clone().Resolve() = %+v, %v, expected a singleton of the clone", s2, err)
+	}
+
+	var log testLogger
+	if err := c.Resolve(&log); err != nil || log != s1.Log || count != 2 {
+		t.Errorf("Resolve() = %v, %v, expected the singleton logger", log, err)
+	}
+	if err := c.Resolve(log); err == nil {
+		t.Errorf("Resolve() with a non-pointer: expected an error, got nil")
+	}
+	var n int
+	if err := c.Resolve(&n); err == nil || err.Error() != "no instance or provider is registered for int" {
+		t.Errorf("Resolve() = %v, expected an error for an unregistered type", err)
+	}
+	c.Register("another", func() *testLog {
+		return &testLog{}
+	})
+	if err := c.Resolve(&log); err == nil || err.Error() != "config.testLogger is resolved ambiguously by the types another, logger" {
+		t.Errorf("Resolve() = %v, expected an error for an ambiguous type", err)
+	}
+}
+
+func TestConfigureWithProviders(t *testing.T) {
+	c := New()
+	c.RegisterInstance(&testDB{"mysql://localhost"})
+	c.RegisterScoped("logger", func() *testLog {
+		return &testLog{}
+	}, PerConfigure)
+	c.Register("service", func(db *testDB, log *testLog) *testService {
+		return &testService{DB: db, Log: log}
+	})
+	c.LoadJSON([]byte(`{
+		"Services": [{"type": "service", "Name": "a"}, {"type": "service", "Name": "b"}],
+		"Log": {"type": "logger", "Prefix": "> "}
+	}`))
+	var app struct {
+		Services []C
+		Log      testLogger
+	}
+	if err := c.Configure(&app); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	s1, s2 := app.Services[0].(*testService), app.Services[1].(*testService)
+	if s1 == s2 || s1.Name != "a" || s2.Name != "b" || s1.DB.DSN != "mysql://localhost" {
+		t.Errorf("Configure() = %+v, %+v", s1, s2)
+	}
+	if s1.Log != s2.Log || s1.Log != app.Log || s1.Log.(*testLog).Prefix != "> " {
+		t.Errorf("Configure() = %v, %v, %v, expected the same logger in a call of Configure()", s1.Log, s2.Log, app.Log)
+	}
+
+	var log testLogger
+	c.Configure(&log, "Log")
+	if log == app.Log {
+		t.Errorf("Configure() reused the logger created by another call")
+	}
+}
+
+func TestCloneSingletons(t *testing.T) {
+	c := New()
+	c.RegisterScoped("logger", func() *testLog {
+		return &testLog{}
+	}, Singleton)
+	c.LoadJSON([]byte(`{"Log": {"type": "logger", "Prefix": "a"}}`))
+	var log1 testLogger
+	if err := c.Configure(&log1, "Log"); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+
+	clone := c.Clone()
+	clone.Set("Log.Prefix", "b")
+	var log2 testLogger
+	if err := clone.Configure(&log2, "Log"); err != nil {
+		t.Fatalf("Clone().Configure(): %v", err)
+	}
+	if err := c.Configure(&log1, "Log"); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	if log1 == log2 || log1.(*testLog).Prefix != "a" || log2.(*testLog).Prefix != "b" {
+		t.Errorf("Configure() = %+v, Clone().Configure() = %+v, expected independent singletons", log1, log2)
+	}
+}
+
+func TestProviderCycle(t *testing.T) {
+	c := New()
+	c.Register("service", func(db *testDB) *testService {
+		return &testService{DB: db}
+	})
+	c.Register("db", func(log testLogger) *testDB {
+		return &testDB{}
+	})
+	c.Register("logger", func(s *testService) *testLog {
+		return &testLog{}
+	})
+	var s *testService
+	err := c.Resolve(&s)
+	if err == nil || !strings.HasSuffix(err.Error(), "dependency cycle detected: service -> db -> logger -> service") {
+		t.Errorf("Resolve() = %v, expected a dependency cycle", err)
+	}
+
+	c.SetData(map[string]interface{}{"type": "service"})
+	var object C
+	err = c.Configure(&object)
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "type" || !strings.Contains(e.Message, "dependency cycle detected") {
+		t.Errorf("Configure() returned %v, expected a ConfigValueError for the dependency cycle", err)
+	}
+
+	if err := c.Register("x", func(args ...int) int { return 0 }); err == nil || err.Error() != "The provider should not be variadic" {
+		t.Errorf("Register() = %v, expected an error for a variadic provider", err)
+	}
+}
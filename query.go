@@ -0,0 +1,373 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// QueryResult represents a configuration value matching a query.
+type QueryResult struct {
+	Path  string      // the path to the value, which can be passed to Get() or Set()
+	Value interface{} // the configuration value
+}
+
+// query segment kinds
+const (
+	segmentKey = iota
+	segmentWildcard
+	segmentRecursive
+	segmentFilter
+)
+
+// querySegment represents a part of a query separated by dots or brackets.
+type querySegment struct {
+	kind   int
+	key    string
+	filter filterExpr
+}
+
+// Query returns all configuration values matching the given query, together with their paths.
+//
+// A query is a path as described in Get(), which may additionally contain the following parts:
+//
+//   - "*" or "[*]" matches every element of a map or array, e.g. "Servers.*.Port";
+//   - "**" matches a value and all of its descendants recursively, e.g. "**.Port" matches
+//     the "Port" values at any level;
+//   - "[?(expr)]" matches the elements of a map or array that satisfy the filter expression,
+//     e.g. "Servers[?(@.Enabled==true)].Name".
+//
+// A query may start with "$", which refers to the whole configuration, as in JSONPath.
+//
+// A filter expression compares a value relative to the element, referred to as "@", with a literal
+// using one of the operators ==, !=, <, <=, >, >=. A literal may be a number, a string in single
+// or double quotes, true, false, or null. Comparisons may be combined with && and ||, and
+// an expression consisting of "@.Path" only checks if the path exists in the element.
+//
+// The results are ordered by the map keys and array indexes along their paths.
+// An error is returned if the query is malformed.
+func (c *Config) Query(query string) ([]QueryResult, error) {
+	segments, err := parseQuery(query)
+	if err != nil {
+		return nil, &ConfigPathError{Path: query, Message: err.Error()}
+	}
+
+	matches := []QueryResult{}
+	if !c.data.IsValid() {
+		return matches, nil
+	}
+	values := []reflect.Value{c.data}
+	paths := []string{""}
+	for _, segment := range segments {
+		var nextValues []reflect.Value
+		var nextPaths []string
+		seen := map[string]bool{}
+		add := func(path string, v reflect.Value) {
+			if !seen[path] {
+				seen[path] = true
+				nextPaths = append(nextPaths, path)
+				nextValues = append(nextValues, v)
+			}
+		}
+		for i, v := range values {
+			switch segment.kind {
+			case segmentKey:
+				if hasElement(v, segment.key) {
+					add(joinPath(paths[i], segment.key), getElement(v, segment.key))
+				}
+			case segmentWildcard, segmentFilter:
+				eachElement(v, func(key string, e reflect.Value) {
+					if segment.kind == segmentWildcard || segment.filter.eval(e) {
+						add(joinPath(paths[i], key), e)
+					}
+				})
+			case segmentRecursive:
+				walkValue(paths[i], v, add)
+			}
+		}
+		values, paths = nextValues, nextPaths
+	}
+
+	for i, v := range values {
+		matches = append(matches, QueryResult{paths[i], valueInterface(v)})
+	}
+	return matches, nil
+}
+
+// eachElement calls the function for every element of a map or array, ordered by the keys or indexes.
+func eachElement(v reflect.Value, f func(key string, e reflect.Value)) {
+	switch v.Kind() {
+	case reflect.Map:
+		keys := mapKeys(v)
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f(name, mapIndex(v, keys[name]))
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			f(strconv.Itoa(i), getElement(v, strconv.Itoa(i)))
+		}
+	}
+}
+
+// walkValue calls the function for a value and all of its descendants.
+func walkValue(path string, v reflect.Value, f func(path string, v reflect.Value)) {
+	f(path, v)
+	eachElement(v, func(key string, e reflect.Value) {
+		walkValue(joinPath(path, key), e, f)
+	})
+}
+
+// parseQuery splits a query into segments.
+func parseQuery(query string) ([]querySegment, error) {
+	if strings.HasPrefix(query, "$") {
+		query = strings.TrimPrefix(query[1:], ".")
+	}
+
+	segments := []querySegment{}
+	n := len(query)
+	for i := 0; ; {
+		var segment querySegment
+		var err error
+		start := i
+		switch {
+		case strings.HasPrefix(query[i:], "[?("):
+			segment.kind = segmentFilter
+			segment.filter, i, err = parseFilter(query, i)
+		case i < n && query[i] == '[':
+			segment.key, i, err = parseBracketKey(query, i)
+			if query[start:i] == "[*]" {
+				segment.kind = segmentWildcard
+			}
+		default:
+			segment.key, i, err = parsePlainKey(query, i)
+			switch query[start:i] {
+			case "*":
+				segment.kind = segmentWildcard
+			case "**":
+				segment.kind = segmentRecursive
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+
+		if i == n {
+			return segments, nil
+		}
+		switch query[i] {
+		case '.':
+			i++
+		case '[':
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %v", query[i], i)
+		}
+	}
+}
+
+// parseFilter parses a filter "[?(expr)]" starting at position i.
+// It returns the filter expression and the position following the closing bracket.
+func parseFilter(query string, i int) (filterExpr, int, error) {
+	start := i
+	depth := 0
+	var quote byte
+	for i += 2; i < len(query); i++ {
+		switch c := query[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth == 0 {
+				if i+1 >= len(query) || query[i+1] != ']' {
+					return nil, i, fmt.Errorf("missing ']' at position %v", i+1)
+				}
+				expr, err := parseFilterExpr(query[start+3 : i])
+				return expr, i + 2, err
+			}
+		}
+	}
+	return nil, i, fmt.Errorf("unterminated filter starting at position %v", start)
+}
+
+// filterExpr represents a filter expression that can be evaluated against a configuration value.
+type filterExpr interface {
+	eval(v reflect.Value) bool
+}
+
+// orExpr is satisfied if any of its expressions is satisfied.
+type orExpr []filterExpr
+
+func (e orExpr) eval(v reflect.Value) bool {
+	for _, expr := range e {
+		if expr.eval(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// andExpr is satisfied if all of its expressions are satisfied.
+type andExpr []filterExpr
+
+func (e andExpr) eval(v reflect.Value) bool {
+	for _, expr := range e {
+		if !expr.eval(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareExpr compares the value at a relative path with a literal.
+// If op is empty, it checks if the relative path exists.
+type compareExpr struct {
+	path    []string
+	op      string
+	literal reflect.Value
+}
+
+func (e *compareExpr) eval(v reflect.Value) bool {
+	for _, key := range e.path {
+		if !hasElement(v, key) {
+			return false
+		}
+		v = getElement(v, key)
+	}
+	switch e.op {
+	case "":
+		return true
+	case "==":
+		return equalValues(v, e.literal)
+	case "!=":
+		return !equalValues(v, e.literal)
+	}
+
+	var r int
+	if f1, ok := numberValue(v); ok {
+		f2, ok := numberValue(e.literal)
+		if !ok {
+			return false
+		}
+		if f1 < f2 {
+			r = -1
+		} else if f1 > f2 {
+			r = 1
+		}
+	} else if v.Kind() == reflect.String && e.literal.Kind() == reflect.String {
+		r = strings.Compare(v.String(), e.literal.String())
+	} else {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	}
+	return r >= 0
+}
+
+// filterOperators lists the comparison operators, with longer operators first.
+var filterOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseFilterExpr parses a filter expression.
+func parseFilterExpr(s string) (filterExpr, error) {
+	var or orExpr
+	for _, conjunction := range splitUnquoted(s, "||") {
+		var and andExpr
+		for _, comparison := range splitUnquoted(conjunction, "&&") {
+			expr, err := parseCompareExpr(strings.TrimSpace(comparison))
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, expr)
+		}
+		or = append(or, and)
+	}
+	return or, nil
+}
+
+// parseCompareExpr parses a comparison such as "@.Port >= 8000".
+func parseCompareExpr(s string) (filterExpr, error) {
+	left, op, right := s, "", ""
+	for _, o := range filterOperators {
+		if parts := splitUnquoted(s, o); len(parts) > 1 {
+			left, op, right = strings.TrimSpace(parts[0]), o, strings.TrimSpace(strings.Join(parts[1:], o))
+			break
+		}
+	}
+
+	if !strings.HasPrefix(left, "@") {
+		return nil, fmt.Errorf("invalid filter expression %q: must start with @", s)
+	}
+	expr := &compareExpr{path: []string{}, op: op}
+	if p := left[1:]; p != "" {
+		if p[0] == '.' {
+			p = p[1:]
+		} else if p[0] != '[' {
+			return nil, fmt.Errorf("invalid filter expression %q", s)
+		}
+		var err error
+		if expr.path, err = parsePath(p); err != nil {
+			return nil, err
+		}
+	}
+	if op == "" {
+		return expr, nil
+	}
+
+	if len(right) > 1 && right[0] == '\'' && right[len(right)-1] == '\'' {
+		right = strconv.Quote(strings.Replace(right[1:len(right)-1], `\'`, `'`, -1))
+	}
+	var literal interface{}
+	if err := json.Unmarshal([]byte(right), &literal); err != nil {
+		return nil, fmt.Errorf("invalid literal %q in filter expression %q", right, s)
+	}
+	expr.literal = reflect.ValueOf(literal)
+	return expr, nil
+}
+
+// splitUnquoted splits a string by the separator which is not enclosed in quotes.
+func splitUnquoted(s, sep string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			i += len(sep) - 1
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte(`{
+		"Name": "app",
+		"Servers": [
+			{"Name": "s1", "Port": 8080, "Enabled": true, "Tags": ["a"]},
+			{"Name": "s2", "Port": 8081, "Enabled": false},
+			{"Name": "s'3", "Port": 9090, "Enabled": true, "Tags": []}
+		],
+		"DB": {
+			"Primary": {"Host": "db1", "Port": 5432},
+			"Replica": {"Host": "db2", "Port": 5433, "Extra": null}
+		},
+		"Hosts": {"example.com": {"Port": 80}}
+	}`))
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`Name`, `Name=app`},
+		{`$.Name`, `Name=app`},
+		{`Missing`, ``},
+		{`Servers.*.Port`, `Servers.0.Port=8080|Servers.1.Port=8081|Servers.2.Port=9090`},
+		{`Servers[*].Name`, `Servers.0.Name=s1|Servers.1.Name=s2|Servers.2.Name=s'3`},
+		{`DB.*.Host`, `DB.Primary.Host=db1|DB.Replica.Host=db2`},
+		{`DB.*.Extra`, `DB.Replica.Extra=<nil>`},
+		{`Name.*`, ``},
+		{`**.Host`, `DB.Primary.Host=db1|DB.Replica.Host=db2`},
+		{`DB.**.Port`, `DB.Primary.Port=5432|DB.Replica.Port=5433`},
+		{`**.**.Host`, `DB.Primary.Host=db1|DB.Replica.Host=db2`},
+		{`Hosts.*.Port`, `Hosts.example\.com.Port=80`},
+		{`Hosts["example.com"].*`, `Hosts.example\.com.Port=80`},
+		{`Servers[?(@.Enabled==true)].Name`, `Servers.0.Name=s1|Servers.2.Name=s'3`},
+		{`$.Servers[?(@.Enabled == false)].Name`, `Servers.1.Name=s2`},
+		{`Servers[?(@.Port >= 8081)].Name`, `Servers.1.Name=s2|Servers.2.Name=s'3`},
+		{`Servers[?(@.Port < 8081)].Name`, `Servers.0.Name=s1`},
+		{`Servers[?(@.Port > 8080 && @.Enabled)].Name`, `Servers.1.Name=s2|Servers.2.Name=s'3`},
+		{`Servers[?(@.Port==8080 || @.Port==9090)].Port`, `Servers.0.Port=8080|Servers.2.Port=9090`},
+		{`Servers[?(@.Tags)].Name`, `Servers.0.Name=s1|Servers.2.Name=s'3`},
+		{`Servers[?(@.Tags[0] == 'a')].Name`, `Servers.0.Name=s1`},
+		{`Servers[?(@.Name == 's\'3')].Port`, `Servers.2.Port=9090`},
+		{`Servers[?(@.Name != "s1")].Port`, `Servers.1.Port=8081|Servers.2.Port=9090`},
+		{`Servers[?(@.Name > "s1")].Port`, `Servers.1.Port=8081`},
+		{`Servers[?(@.Name > 1)].Port`, ``},
+		{`DB[?(@.Host == "db2")].Port`, `DB.Replica.Port=5433`},
+		{`DB[?(@.Extra == null)].Host`, `DB.Replica.Host=db2`},
+		{`Servers.1[?(@ == "s2")]`, `Servers.1.Name=s2`},
+	}
+	for _, test := range tests {
+		results, err := c.Query(test.query)
+		if err != nil {
+			t.Errorf("Query(%q): %v", test.query, err)
+			continue
+		}
+		s := make([]string, len(results))
+		for i, result := range results {
+			s[i] = fmt.Sprintf("%v=%v", result.Path, result.Value)
+			if v := c.Get(result.Path); v != result.Value {
+				t.Errorf("Query(%q): Get(%q) = %v, expected %v", test.query, result.Path, v, result.Value)
+			}
+		}
+		if r := strings.Join(s, "|"); r != test.expected {
+			t.Errorf("Query(%q) = %q, expected %q", test.query, r, test.expected)
+		}
+	}
+
+	errors := []string{
+		`Servers[?(@.Port == 1]`,
+		`Servers[?(@.Port == 1)`,
+		`Servers[?(@.Port == 1)]x`,
+		`Servers[?(Port == 1)]`,
+		`Servers[?(@Port == 1)]`,
+		`Servers[?(@.Port == abc)]`,
+		`Servers[0`,
+	}
+	for _, query := range errors {
+		if _, err := c.Query(query); err == nil {
+			t.Errorf("Query(%q) expected an error, got nil", query)
+		}
+	}
+
+	if results, err := New().Query("**"); err != nil || len(results) != 0 {
+		t.Errorf(`New().Query("**") = %v, %v, expected no results`, results, err)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RedactedValue is the value that replaces the masked configuration values.
+const RedactedValue = "******"
+
+// DefaultRedactPatterns lists the patterns of the paths whose values are masked by Redacted()
+// when no pattern is given, and by String().
+var DefaultRedactPatterns = []string{"*password*", "*passwd*", "*secret*", "*token*", "*apikey*", "*api_key*", "*private_key*", "*privatekey*"}
+
+// Redacted returns a copy of the configuration data with the values that may contain sensitive
+// information masked with RedactedValue. It is mainly used to log or display the configuration.
+//
+// A value is masked if its path matches any of the given patterns, if it was decrypted when it was loaded,
+// or if it corresponds to a struct field with the tag `config:",secret"` in a value passed to Configure()
+// or ConfigureStrict(), or in a type registered by Register() and named by the type element of a map.
+// The secret fields are found from the types, so they are masked even if they have not been configured.
+// If no pattern is given, DefaultRedactPatterns will be used.
+//
+// A pattern is matched against the trailing keys of a path, with each key in the pattern matched
+// case-insensitively using the syntax of path.Match. For example, "*.password" and "password" match
+// "DB.Password", "TLS.Key" matches "Server.TLS.Key", and "*secret*" matches "Auth.ClientSecret".
+// When a map or array is matched, all values inside it are masked.
+//
+// Maps in the returned data are of type map[string]interface{}, and arrays are of type []interface{},
+// so that the data can be encoded in JSON even if it is loaded from YAML.
+func (c *Config) Redacted(patterns ...string) interface{} {
+	if len(patterns) == 0 {
+		patterns = DefaultRedactPatterns
+	}
+	keys := make([][]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if parts, err := parsePath(strings.ToLower(pattern)); err == nil {
+			keys = append(keys, parts)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	secrets := make([]secretField, 0, len(c.secretFields))
+	for _, field := range c.secretFields {
+		secrets = append(secrets, field)
+	}
+	return c.redact(c.data, "", []string{}, keys, secrets)
+}
+
+// String returns the configuration data in JSON format, with the sensitive values masked
+// by Redacted() using DefaultRedactPatterns.
+func (c *Config) String() string {
+	bytes, err := json.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(bytes)
+}
+
+// redact returns a copy of the value with the values matching the patterns masked, as well as the values
+// of the secret fields located relative to the value. Maps in the copy are of type map[string]interface{},
+// and arrays are of type []interface{}.
+func (c *Config) redact(v reflect.Value, p string, keys []string, patterns [][]string, secrets []secretField) interface{} {
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	// a map naming a registered type contains the secret fields of the type
+	secrets = append(secrets[:len(secrets):len(secrets)], secretField{typeKey: c.typeKey})
+	secret := false
+	for i := 0; i < len(secrets) && !secret; i++ {
+		if len(secrets[i].keys) > 0 {
+			continue
+		}
+		if secrets[i].typeKey == "" {
+			secret = true
+		} else if name, ok := c.typeName(v, secrets[i].typeKey); ok {
+			secrets = append(secrets, c.typeSecrets[name]...)
+		}
+	}
+	if len(keys) > 0 && (secret || c.secretPaths[c.sourceKey(p)] || matchPatterns(keys, patterns)) {
+		return RedactedValue
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			name := keyString(key)
+			m[name] = c.redact(v.MapIndex(key), joinPath(p, name), append(keys[:len(keys):len(keys)], strings.ToLower(name)), patterns, c.nextSecretFields(secrets, name))
+		}
+		return m
+	case reflect.Array, reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			name := strconv.Itoa(i)
+			s[i] = c.redact(v.Index(i), joinPath(p, name), append(keys[:len(keys):len(keys)], name), patterns, c.nextSecretFields(secrets, "*"))
+		}
+		return s
+	}
+	return valueInterface(v)
+}
+
+// secretField locates the values of a struct field with the "secret" option in its "config" tag,
+// relative to a configuration value.
+type secretField struct {
+	keys    []string // the keys along the path to the values, where "*" matches any map key or array index
+	typeKey string   // if not empty, the values located are maps naming registered types by this key, and the secret fields of the types are located relative to them
+}
+
+// addSecretFields records the secret fields in the values of the given type, which is configured
+// with the configuration value at the path represented by the keys.
+func (c *Config) addSecretFields(keys []string, t reflect.Type) {
+	fields := c.findSecretFields(t, c.typeKey, map[reflect.Type]bool{})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, field := range fields {
+		field.keys = append(keys[:len(keys):len(keys)], field.keys...)
+		c.secretFields[fmt.Sprintf("%q %q", field.keys, field.typeKey)] = field
+	}
+}
+
+// registeredSecretFields returns the secret fields in the instances created by a provider with the given output
+// type, relative to the configuration map naming the registered type.
+func (c *Config) registeredSecretFields(t reflect.Type) []secretField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+	fields := c.findSecretFields(t, c.typeKey, map[reflect.Type]bool{})
+	if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+		// the instance is configured with the "value" element
+		for i := range fields {
+			fields[i].keys = append([]string{valueKey.String()}, fields[i].keys...)
+		}
+	}
+	return fields
+}
+
+// findSecretFields returns the secret fields in the values of the given type, relative to the configuration
+// value that configures them. The type key is the key of the type element in the configuration maps used to
+// configure interfaces. The types being visited are skipped, so that a recursive type is visited only once.
+func (c *Config) findSecretFields(t reflect.Type, typeKey string, visiting map[reflect.Type]bool) []secretField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var fields []secretField
+	add := func(key string, t reflect.Type, typeKey string) {
+		for _, field := range c.findSecretFields(t, typeKey, visiting) {
+			fields = append(fields, secretField{append([]string{key}, field.keys...), field.typeKey})
+		}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		for _, f := range c.configurableFields(t) {
+			if f.PkgPath != "" {
+				continue
+			}
+			options := parseTag(f)
+			if _, ok := options["secret"]; ok {
+				fields = append(fields, secretField{keys: []string{f.Name}})
+			} else if key := options["typekey"]; key != "" {
+				add(f.Name, f.Type, key)
+			} else {
+				add(f.Name, f.Type, c.typeKey)
+			}
+		}
+		// an embedded struct may also be configured as a whole by its type name
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); isEmbeddedStruct(f) && f.PkgPath == "" {
+				add(f.Name, f.Type, c.typeKey)
+			}
+		}
+	case reflect.Array, reflect.Slice, reflect.Map:
+		add("*", t.Elem(), typeKey)
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			fields = append(fields, secretField{typeKey: typeKey})
+		}
+	}
+	return fields
+}
+
+// nextSecretFields returns the secret fields located relative to the element with the given key,
+// given those located relative to a map or array. The key "*" stands for an array index.
+func (c *Config) nextSecretFields(fields []secretField, key string) []secretField {
+	var next []secretField
+	for _, field := range fields {
+		if len(field.keys) == 0 {
+			continue
+		}
+		if k := field.keys[0]; k == "*" || k == key || c.ignoreCase && strings.EqualFold(k, key) {
+			next = append(next, secretField{field.keys[1:], field.typeKey})
+		}
+	}
+	return next
+}
+
+// typeName returns the name of the registered type specified by the type element of a configuration map.
+func (c *Config) typeName(v reflect.Value, typeKey string) (string, bool) {
+	if v.Kind() != reflect.Map {
+		return "", false
+	}
+	for _, k := range v.MapKeys() {
+		if name := keyString(k); name == typeKey || c.ignoreCase && strings.EqualFold(name, typeKey) {
+			e := v.MapIndex(k)
+			for e.Kind() == reflect.Interface {
+				e = e.Elem()
+			}
+			if e.Kind() == reflect.String {
+				_, ok := c.types[e.String()]
+				return e.String(), ok
+			}
+		}
+	}
+	return "", false
+}
+
+// matchPatterns checks if the trailing keys of a path match any of the patterns.
+func matchPatterns(keys []string, patterns [][]string) bool {
+	for _, pattern := range patterns {
+		if len(pattern) > len(keys) {
+			continue
+		}
+		matched := true
+		for i, k := range keys[len(keys)-len(pattern):] {
+			if ok, _ := path.Match(pattern[i], k); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedacted(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte(`{
+		"Name": "app",
+		"DB": {"User": "root", "Password": "pass", "Options": {"Token": "abc"}},
+		"TLS": {"Cert": "cert", "Key": "key"},
+		"Auth": {"ClientSecret": "secret", "Keys": ["k1", "k2"]},
+		"Servers": [{"Host": "s1", "Password": "p1"}]
+	}`))
+
+	tests := []struct {
+		patterns []string
+		expected string
+	}{
+		{nil, `{"Auth":{"ClientSecret":"******","Keys":["k1","k2"]},"DB":{"Options":{"Token":"******"},"Password":"******","User":"root"},"Name":"app","Servers":[{"Host":"s1","Password":"******"}],"TLS":{"Cert":"cert","Key":"key"}}`},
+		{[]string{"*.password", "TLS.Key"}, `{"Auth":{"ClientSecret":"secret","Keys":["k1","k2"]},"DB":{"Options":{"Token":"abc"},"Password":"******","User":"root"},"Name":"app","Servers":[{"Host":"s1","Password":"******"}],"TLS":{"Cert":"cert","Key":"******"}}`},
+		{[]string{"auth.keys"}, `{"Auth":{"ClientSecret":"secret","Keys":"******"},"DB":{"Options":{"Token":"abc"},"Password":"pass","User":"root"},"Name":"app","Servers":[{"Host":"s1","Password":"p1"}],"TLS":{"Cert":"cert","Key":"key"}}`},
+		{[]string{"Servers.*.Host", "Keys.1"}, `{"Auth":{"ClientSecret":"secret","Keys":["k1","******"]},"DB":{"Options":{"Token":"abc"},"Password":"pass","User":"root"},"Name":"app","Servers":[{"Host":"******","Password":"p1"}],"TLS":{"Cert":"cert","Key":"key"}}`},
+	}
+	for _, test := range tests {
+		s, _ := json.Marshal(c.Redacted(test.patterns...))
+		if string(s) != test.expected {
+			t.Errorf("Redacted(%q) = %v, expected %v", test.patterns, string(s), test.expected)
+		}
+	}
+
+	// the original data is not modified
+	if v := c.Get("DB.Password"); v != "pass" {
+		t.Errorf(`Get("DB.Password") = %v, expected %v`, v, "pass")
+	}
+	if s := c.String(); s != tests[0].expected {
+		t.Errorf("String() = %v, expected %v", s, tests[0].expected)
+	}
+	if s := New().String(); s != "null" {
+		t.Errorf("New().String() = %v, expected %v", s, "null")
+	}
+}
+
+func TestRedactedWithSecretTag(t *testing.T) {
+	c := New()
+	c.Load("testdata/c1.yaml")
+
+	var obj struct {
+		A1 string `config:",secret"`
+		A2 int
+		A3 bool
+		A4 float64
+		A5 interface{}
+		A6 struct {
+			B1 string
+			B2 struct {
+				C1 string `config:",secret"`
+			}
+		}
+		A7 []string
+	}
+	if err := c.Configure(&obj); err != nil {
+		t.Errorf("Configure(): %v", err)
+		return
+	}
+	if obj.A1 != "a1" || obj.A6.B2.C1 != "c1" {
+		t.Errorf("Configure() = %+v", obj)
+	}
+
+	s, _ := json.Marshal(c.Redacted())
+	expected := `{"A1":"******","A2":2,"A3":true,"A4":2.13,"A5":null,"A6":{"B1":"b1","B2":{"C1":"******"}},"A7":["d1","d2"]}`
+	if string(s) != expected {
+		t.Errorf("Redacted() = %v, expected %v", string(s), expected)
+	}
+	if s := c.Clone().String(); s != expected {
+		t.Errorf("Clone().String() = %v, expected %v", s, expected)
+	}
+
+	var b2 struct {
+		C1 string `config:",secret"`
+	}
+	c = New()
+	c.Load("testdata/c1.yaml")
+	if err := c.Configure(&b2, "A6[B2]"); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+	if v := c.Redacted().(map[string]interface{})["A6"].(map[string]interface{})["B2"].(map[string]interface{})["C1"]; v != RedactedValue {
+		t.Errorf("Redacted() contains %v for A6.B2.C1, expected %v", v, RedactedValue)
+	}
+}
+
+type redactedPlugin struct {
+	Name  string
+	Token string `config:",secret"`
+}
+
+func (p *redactedPlugin) Foo() {
+}
+
+func TestRedactedWithSecretTypes(t *testing.T) {
+	c := New()
+	c.Register("plugin", func() *redactedPlugin {
+		return &redactedPlugin{}
+	})
+	c.LoadJSON([]byte(`{
+		"Servers": [{"Host": "s1", "Key": "k1"}, {"Host": "s2", "Key": "k2"}],
+		"Plugins": {"a": {"kind": "plugin", "Name": "a", "Token": "t1"}},
+		"Extra": {"type": "plugin", "Token": "t2"},
+		"Port": "x"
+	}`))
+
+	// the registered types are masked wherever they are named by the type key
+	expected := `{"Extra":{"Token":"******","type":"plugin"},"Plugins":{"a":{"Name":"a","Token":"t1","kind":"plugin"}},"Port":"x","Servers":[{"Host":"s1","Key":"k1"},{"Host":"s2","Key":"k2"}]}`
+	if s, _ := json.Marshal(c.Redacted("none")); string(s) != expected {
+		t.Errorf("Redacted() = %v, expected %v", string(s), expected)
+	}
+
+	// the secret fields are found from the type, even if Configure() fails before configuring them
+	var app struct {
+		Port    int
+		Servers []struct {
+			Host string
+			Key  string `config:",secret"`
+		}
+		Plugins map[string]C `config:",typekey=kind"`
+	}
+	if err := c.Configure(&app); err == nil {
+		t.Errorf("Configure() expected an error for %q, got nil", "Port")
+	}
+	expected = `{"Extra":{"Token":"******","type":"plugin"},"Plugins":{"a":{"Name":"a","Token":"******","kind":"plugin"}},"Port":"x","Servers":[{"Host":"s1","Key":"******"},{"Host":"s2","Key":"******"}]}`
+	if s, _ := json.Marshal(c.Redacted("none")); string(s) != expected {
+		t.Errorf("Redacted() = %v, expected %v", string(s), expected)
+	}
+
+	// the values set later are also masked
+	c.Set("Servers.0.Key", "k3")
+	if s := c.String(); strings.Contains(s, "k3") {
+		t.Errorf("String() = %v, expected %v to be masked", s, "Servers.0.Key")
+	}
+	if s := c.Clone().String(); strings.Contains(s, "t1") {
+		t.Errorf("Clone().String() = %v, expected %v to be masked", s, "Plugins.a.Token")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// secretPrefix is the prefix of a secret reference.
+const secretPrefix = "secret://"
+
+// SecretResolver resolves secret references into the secret values.
+//
+// A secret reference is a string configuration value in the format of "secret://scheme/reference",
+// such as "secret://file/run/secrets/db_password". The resolver registered for the scheme is given
+// the part following the scheme, e.g., "/run/secrets/db_password".
+type SecretResolver interface {
+	// Resolve returns the secret value that the reference refers to.
+	Resolve(ref string) (string, error)
+}
+
+// SecretResolverFunc is an adapter to allow the use of ordinary functions as SecretResolvers.
+type SecretResolverFunc func(ref string) (string, error)
+
+// Resolve calls f(ref).
+func (f SecretResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// FileSecretResolver resolves secret references in the format of "secret://file/path/to/file"
+// by reading the content of the file "/path/to/file". Trailing newlines in the file are removed.
+// It is suitable for the secrets mounted as files by Docker or Kubernetes.
+var FileSecretResolver = SecretResolverFunc(func(ref string) (string, error) {
+	bytes, err := ioutil.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(bytes), "\r\n"), nil
+})
+
+// EnvSecretResolver resolves secret references in the format of "secret://env/NAME"
+// by reading the environment variable NAME.
+var EnvSecretResolver = SecretResolverFunc(func(ref string) (string, error) {
+	name := strings.TrimPrefix(ref, "/")
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("environment variable %v is not set", name)
+})
+
+// RegisterSecretResolver associates a secret reference scheme with a resolver.
+//
+// Secret references are string configuration values in the format of "secret://scheme/reference".
+// They are resolved by the resolver registered for the scheme when they are accessed by Get() or
+// used by Configure(), while Data() and other methods returning the configuration data keep the
+// references as they are, so that the secret values are not exposed accidentally.
+//
+// The "file" and "env" schemes are registered by default with FileSecretResolver and EnvSecretResolver.
+func (c *Config) RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	c.secrets[scheme] = resolver
+}
+
+// resolveSecret resolves a secret reference.
+func (c *Config) resolveSecret(ref string) (string, error) {
+	s := strings.TrimPrefix(ref, secretPrefix)
+	scheme, name := s, ""
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		scheme, name = s[:i], s[i:]
+	}
+	resolver, ok := c.secrets[scheme]
+	if !ok {
+		return "", fmt.Errorf("unknown secret scheme %q in %q", scheme, ref)
+	}
+	value, err := resolver.Resolve(name)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve secret %q: %v", ref, err)
+	}
+	return value, nil
+}
+
+// resolveSecrets returns the value with all secret references in it resolved. If the value is a map or array
+// containing secret references, a copy of it with the resolved secrets is returned and the value is not modified.
+func (c *Config) resolveSecrets(v reflect.Value, path string) (reflect.Value, error) {
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !containsSecret(v) {
+		return v, nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, err := c.resolveSecret(v.String())
+		if err != nil {
+			return v, c.valueError(path, err.Error())
+		}
+		return reflect.ValueOf(s).Convert(v.Type()), nil
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, key := range v.MapKeys() {
+			e, err := c.resolveSecrets(v.MapIndex(key), joinPath(path, keyString(key)))
+			if err != nil {
+				return v, err
+			}
+			if !e.IsValid() {
+				e = reflect.Zero(v.Type().Elem())
+			}
+			m.SetMapIndex(key, e)
+		}
+		return m, nil
+	}
+
+	// arrays and slices
+	s := reflect.New(v.Type()).Elem()
+	if v.Kind() == reflect.Slice {
+		s.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+	}
+	for i := 0; i < v.Len(); i++ {
+		e, err := c.resolveSecrets(v.Index(i), joinPath(path, fmt.Sprint(i)))
+		if err != nil {
+			return v, err
+		}
+		if e.IsValid() {
+			s.Index(i).Set(e)
+		}
+	}
+	return s, nil
+}
+
+// containsSecret checks if a value is or contains a secret reference.
+func containsSecret(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface:
+		return containsSecret(v.Elem())
+	case reflect.String:
+		return strings.HasPrefix(v.String(), secretPrefix)
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if containsSecret(v.MapIndex(key)) {
+				return true
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if containsSecret(v.Index(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
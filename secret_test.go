@@ -0,0 +1,93 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	file, _ := filepath.Abs("testdata/secret.txt")
+	os.Setenv("OZZO_CONFIG_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("OZZO_CONFIG_TEST_SECRET")
+
+	c := New()
+	c.RegisterSecretResolver("upper", SecretResolverFunc(func(ref string) (string, error) {
+		if ref == "" {
+			return "", errors.New("empty reference")
+		}
+		return strings.ToUpper(strings.TrimPrefix(ref, "/")), nil
+	}))
+	c.SetData(map[string]interface{}{
+		"DB": map[string]interface{}{
+			"User":     "root",
+			"Password": "secret://file" + filepath.ToSlash(file),
+			"Token":    "secret://env/OZZO_CONFIG_TEST_SECRET",
+			"Keys":     []interface{}{"secret://upper/abc", "plain", nil},
+		},
+		"Missing": "secret://env/OZZO_CONFIG_TEST_MISSING",
+		"Unknown": "secret://vault/db",
+		"Empty":   "secret://upper",
+	})
+
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"DB.User", "root"},
+		{"DB.Password", "s3cret"},
+		{"DB.Token", "env-secret"},
+		{"DB.Keys.0", "ABC"},
+		{"Missing", nil},
+		{"Unknown", nil},
+		{"Empty", nil},
+	}
+	for _, test := range tests {
+		if v := c.Get(test.path); v != test.expected {
+			t.Errorf("Get(%q) = %v, expected %v", test.path, v, test.expected)
+		}
+	}
+	if v := c.GetString("Missing", "default"); v != "default" {
+		t.Errorf(`GetString("Missing", "default") = %v, expected %v`, v, "default")
+	}
+
+	// subtrees are resolved without modifying the configuration data
+	s, _ := json.Marshal(c.Get("DB"))
+	expected := `{"Keys":["ABC","plain",null],"Password":"s3cret","Token":"env-secret","User":"root"}`
+	if string(s) != expected {
+		t.Errorf(`Get("DB") = %v, expected %v`, string(s), expected)
+	}
+	if v := c.Data().(map[string]interface{})["DB"].(map[string]interface{})["Token"]; v != "secret://env/OZZO_CONFIG_TEST_SECRET" {
+		t.Errorf(`Data() contains %v, expected the secret reference`, v)
+	}
+
+	// Configure
+	var db struct {
+		User     string
+		Password string
+		Token    []byte
+		Keys     []interface{}
+	}
+	if err := c.Configure(&db, "DB"); err != nil {
+		t.Errorf(`Configure(&db, "DB"): %v`, err)
+	} else if db.Password != "s3cret" || string(db.Token) != "env-secret" || db.Keys[0] != "ABC" {
+		t.Errorf(`Configure(&db, "DB") = %+v`, db)
+	}
+	var all interface{}
+	err := c.Configure(&all)
+	if e, ok := err.(*ConfigValueError); !ok || (e.Path != "Missing" && e.Path != "Unknown" && e.Path != "Empty") {
+		t.Errorf("Configure(&all) returned %v, expected a ConfigValueError", err)
+	}
+
+	clone := c.Clone()
+	if v := clone.Get("DB.Keys.0"); v != "ABC" {
+		t.Errorf(`Clone().Get("DB.Keys.0") = %v, expected %v`, v, "ABC")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Source returns the source of the configuration value at the specified path, i.e., where the value
+// came from. The source is the name of the file if the value was loaded by Load(), or the name of the
+// method that provided the value otherwise, such as "LoadJSON", "SetData", "Set", or "ApplyPatch".
+//
+// Sources are tracked for scalar values only. An empty string is returned if the path refers
+// to a map or array, or if it does not exist.
+func (c *Config) Source(path string) string {
+	parts, err := parsePath(path)
+	if err != nil {
+		return ""
+	}
+	return c.sources[c.sourceKey(PathOf(parts...))]
+}
+
+// Sources returns the sources of all scalar values under the specified path, indexed by their paths.
+// If the path is not given, the sources of all values in the configuration are returned.
+// See Source() for more details.
+func (c *Config) Sources(path ...string) map[string]string {
+	sources := map[string]string{}
+	parts := []string{}
+	if len(path) > 0 && path[0] != "" {
+		var err error
+		if parts, err = parsePath(path[0]); err != nil {
+			return sources
+		}
+	}
+
+	walkValue(PathOf(parts...), c.lookup(c.data, parts), func(p string, v reflect.Value) {
+		if source, ok := c.sources[c.sourceKey(p)]; ok {
+			sources[p] = source
+		}
+	})
+	return sources
+}
+
+// recordSources records the source of all scalar values in v, which is merged into the configuration at the path.
+func (c *Config) recordSources(path string, v reflect.Value, source string) {
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	key := c.sourceKey(path)
+	delete(c.sources, key)
+	delete(c.positions, key)
+	switch {
+	case isDeleteMarker(v):
+		c.removeSources(path)
+	case v.Kind() == reflect.Map:
+		for _, k := range v.MapKeys() {
+			c.recordSources(joinPath(path, keyString(k)), v.MapIndex(k), source)
+		}
+	case v.Kind() == reflect.Array || v.Kind() == reflect.Slice:
+		c.removeSources(path)
+		for i := 0; i < v.Len(); i++ {
+			c.recordSources(joinPath(path, strconv.Itoa(i)), v.Index(i), source)
+		}
+	default:
+		c.removeSources(path)
+		c.sources[key] = source
+	}
+}
+
+// removeSources removes the sources and positions of the value at the path and all values under it.
+func (c *Config) removeSources(path string) {
+	if path == "" {
+		c.sources = make(map[string]string)
+		c.positions = make(map[string]Position)
+		return
+	}
+	key := c.sourceKey(path)
+	for p := range c.sources {
+		if p == key || strings.HasPrefix(p, key+".") {
+			delete(c.sources, p)
+		}
+	}
+	for p := range c.positions {
+		if p == key || strings.HasPrefix(p, key+".") {
+			delete(c.positions, p)
+		}
+	}
+}
+
+// sourceKey returns the key used to store the source of the value at the path.
+func (c *Config) sourceKey(path string) string {
+	if c.ignoreCase {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// recordChanges records the source of the values that are changed when the configuration data is replaced.
+func (c *Config) recordChanges(old, data reflect.Value, source string) {
+	for _, change := range diff(nil, "", old, data) {
+		if change.Kind == ChangeRemoved {
+			c.removeSources(change.Path)
+		} else {
+			c.recordSources(change.Path, reflect.ValueOf(change.New), source)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	c := New()
+	if err := c.Load("testdata/c1.yaml", "testdata/c2.yaml"); err != nil {
+		t.Errorf("Load(): %v", err)
+		return
+	}
+	c.LoadJSON([]byte(`{"A7": ["x"]}`))
+	c.Set("A6.B3", "b3")
+
+	tests := []struct {
+		path, expected string
+	}{
+		{"A1", "testdata/c1.yaml"},
+		{"A2", "testdata/c2.yaml"},
+		{"A5", "testdata/c2.yaml"},
+		{"A6.B1", "testdata/c1.yaml"},
+		{"A6.B2.C1", "testdata/c1.yaml"},
+		{"A6[B2][C2]", "testdata/c2.yaml"},
+		{"A6.B3", "Set"},
+		{"A7.0", "LoadJSON"},
+		{"A7.1", ""},
+		{"A6", ""},
+		{"A8", ""},
+		{"A6[", ""},
+	}
+	for _, test := range tests {
+		if source := c.Source(test.path); source != test.expected {
+			t.Errorf("Source(%q) = %q, expected %q", test.path, source, test.expected)
+		}
+	}
+
+	expected := map[string]string{
+		"A6.B1":    "testdata/c1.yaml",
+		"A6.B2.C1": "testdata/c1.yaml",
+		"A6.B2.C2": "testdata/c2.yaml",
+		"A6.B3":    "Set",
+	}
+	if sources := c.Sources("A6"); !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Sources(%q) = %v, expected %v", "A6", sources, expected)
+	}
+	if sources := c.Sources(); len(sources) != 10 {
+		t.Errorf("Sources() returned %v sources, expected %v", len(sources), 10)
+	}
+
+	c.Set("A6", nil)
+	if sources := c.Sources("A6"); len(sources) != 0 {
+		t.Errorf("Sources(%q) = %v, expected none", "A6", sources)
+	}
+	if source := c.Clone().Source("A1"); source != "testdata/c1.yaml" {
+		t.Errorf("Clone().Source(%q) = %q, expected %q", "A1", source, "testdata/c1.yaml")
+	}
+
+	c.ApplyMergePatch([]byte(`{"A1": "x", "A2": null, "A3": {"B1": 1}}`))
+	for path, expected := range map[string]string{"A1": "ApplyMergePatch", "A2": "", "A3.B1": "ApplyMergePatch", "A4": "testdata/c1.yaml"} {
+		if source := c.Source(path); source != expected {
+			t.Errorf("Source(%q) = %q, expected %q", path, source, expected)
+		}
+	}
+
+	c.SetData(map[string]interface{}{"A": 1})
+	expected = map[string]string{"A": "SetData"}
+	if sources := c.Sources(); !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Sources() = %v, expected %v", sources, expected)
+	}
+}
+
+func TestSourceWithDeleteMarkers(t *testing.T) {
+	c := New(WithCaseInsensitiveKeys())
+	c.LoadJSON([]byte(`{"DB": {"Host": "localhost", "Port": 3306}}`))
+	c.LoadJSON([]byte(`{"db": {"host": "db.example.com", "Port": {"$delete": true}}}`))
+	expected := map[string]string{"DB.Host": "LoadJSON"}
+	if sources := c.Sources(); !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Sources() = %v, expected %v", sources, expected)
+	}
+	if source := c.Source("db.HOST"); source != "LoadJSON" {
+		t.Errorf("Source(%q) = %q, expected %q", "db.HOST", source, "LoadJSON")
+	}
+}
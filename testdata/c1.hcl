@@ -0,0 +1,14 @@
+# the first HCL configuration
+A1 = "a1"
+A2 = 2
+A3 = true
+A4 = 2.13
+A7 = ["d1", "d2"]
+
+A6 {
+  B1 = "b1"
+}
+
+A6 "B2" {
+  C1 = "c1"
+}
@@ -0,0 +1,7 @@
+// the second HCL configuration
+A2 = 3
+A5 = "a5"
+
+A6 "B2" {
+  C2 = "c2"
+}
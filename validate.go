@@ -0,0 +1,291 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validator is implemented by the values that validate themselves after they are configured.
+//
+// After Configure() or ConfigureStrict() fills a value, the Validate() method is called for the value
+// and every value nested in it that implements Validator. The nested values are validated first.
+// If a struct has a Validate() method, either declared for it or promoted from an embedded struct, the method
+// is called for the outer struct only, and not for the embedded structs having a method of the same name.
+// A method declared for the outer struct thus overrides those of the embedded structs, which it may call
+// explicitly. The method is not called if it may be promoted from an embedded struct through a nil pointer.
+type Validator interface {
+	Validate() error
+}
+
+// validatorType is the type of Validator.
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+// validate validates the value located at the path and the values nested in it.
+//
+// For every exported struct field, the rules in the "validate" tag of the field are checked, then the field
+// is validated recursively, and finally the Validate() method is called if the value implements Validator.
+// The fields of an embedded struct are validated with the paths in the outer struct.
+// Validation errors are returned as ConfigValueError with the paths of the values.
+//
+// Only the values pointed to by the pointers followed or allocated by configure() are validated, and each of them
+// is validated once, so that the values not being configured and the cyclic references are skipped.
+func (c *configurer) validate(v reflect.Value, path string) error {
+	return c.validateValue(v, path, true)
+}
+
+// validateValue validates the value as described in validate(). The Validate() method of the value
+// is not called if call is false, which is the case for an embedded struct whose method is called
+// for the outer struct.
+func (c *configurer) validateValue(v reflect.Value, path string, call bool) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Ptr {
+			key := pointerKey{v.Pointer(), v.Type().Elem()}
+			if !c.configured[key] || c.validated[key] {
+				return nil
+			}
+			if c.validated == nil {
+				c.validated = map[pointerKey]bool{}
+			}
+			c.validated[key] = true
+		}
+		v = v.Elem()
+	}
+
+	validator := validatorOf(v)
+	if validator != nil && promotedThroughNil(v) {
+		validator = nil
+	}
+	// whether the Validate() methods of the embedded structs are called for this or an outer struct
+	outer := validator != nil || !call
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !isEmbeddedStruct(f) {
+				continue
+			}
+			// the fields of an embedded struct are located in the map of the outer struct
+			p := path
+			if !isEmbeddedStruct(f) {
+				p = joinPath(path, f.Name)
+			}
+			if rules := f.Tag.Get("validate"); rules != "" {
+				if err := c.checkRules(v.Field(i), rules, p); err != nil {
+					return err
+				}
+			}
+			promoted := outer && isEmbeddedStruct(f) && hasValidateMethod(embeddedType(f))
+			if err := c.validateValue(v.Field(i), p, !promoted); err != nil {
+				return err
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := c.validate(v.Index(i), joinPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		var err error
+		eachElement(v, func(key string, e reflect.Value) {
+			if err == nil {
+				err = c.validate(e, joinPath(path, key))
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !call || validator == nil {
+		return nil
+	}
+	if err := validator.Validate(); err != nil {
+		if _, ok := err.(*ConfigValueError); ok {
+			return err
+		}
+		return c.valueError(path, err.Error())
+	}
+	return nil
+}
+
+// validatorOf returns the value, or its address if it is addressable, as a Validator.
+// Nil is returned if neither of them implements Validator.
+func validatorOf(v reflect.Value) Validator {
+	if !v.CanInterface() {
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(validatorType) {
+		return v.Addr().Interface().(Validator)
+	}
+	validator, _ := v.Interface().(Validator)
+	return validator
+}
+
+// promotedThroughNil checks if a Validate() method of the struct may be promoted from an embedded struct
+// through a nil pointer, in which case calling the method would dereference the nil pointer.
+func promotedThroughNil(v reflect.Value) bool {
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !isEmbeddedStruct(f) || !hasValidateMethod(embeddedType(f)) {
+			continue
+		}
+		e := v.Field(i)
+		if e.Kind() == reflect.Ptr {
+			if e.IsNil() {
+				return true
+			}
+			e = e.Elem()
+		}
+		if promotedThroughNil(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasValidateMethod checks if the type or the pointer to it has a Validate() method,
+// which is promoted into the method set of a struct embedding the type unless it is ambiguous.
+func hasValidateMethod(t reflect.Type) bool {
+	_, ok := reflect.PtrTo(t).MethodByName("Validate")
+	return ok
+}
+
+// checkRules checks if the value located at the path satisfies the validation rules in the format of
+// "min=1,max=65535". The following rules are supported:
+//
+//   - nonzero: the value must not be a zero value, e.g. an empty string or a nil slice.
+//   - min=N, max=N: a number must be no less (greater) than N, and the length of a string, slice,
+//     or map must be no less (greater) than N. N may be a duration such as "1s" for time.Duration values.
+//   - oneof=a|b|c: the value, formatted by fmt.Sprint(), must be one of the given options.
+//   - regexp=PATTERN: a string must match the regular expression. Because the pattern may contain
+//     commas, this must be the last rule in the tag.
+func (c *Config) checkRules(v reflect.Value, rules, path string) error {
+	for rules != "" {
+		var rule string
+		if strings.HasPrefix(rules, "regexp=") {
+			rule, rules = rules, ""
+		} else if i := strings.IndexByte(rules, ','); i >= 0 {
+			rule, rules = rules[:i], rules[i+1:]
+		} else {
+			rule, rules = rules, ""
+		}
+		name, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		if err := checkRule(v, name, arg); err != nil {
+			return c.valueError(path, err.Error())
+		}
+	}
+	return nil
+}
+
+// checkRule checks if the value satisfies a single validation rule.
+func checkRule(v reflect.Value, name, arg string) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if name == "nonzero" {
+				return fmt.Errorf("the value must be set")
+			}
+			// the other rules apply to the values that are set only
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch name {
+	case "nonzero":
+		if v.IsZero() {
+			return fmt.Errorf("the value must be set")
+		}
+	case "min", "max":
+		x, isLength, err := ruleOperand(v)
+		if err != nil {
+			return err
+		}
+		limit, err := ruleLimit(v, arg)
+		if err != nil {
+			return fmt.Errorf("invalid validation rule %v=%v: %v", name, arg, err)
+		}
+		what := "the value"
+		if isLength {
+			what = "the length"
+		}
+		if name == "min" && x < limit {
+			return fmt.Errorf("%v must be at least %v", what, arg)
+		}
+		if name == "max" && x > limit {
+			return fmt.Errorf("%v must be at most %v", what, arg)
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		options := strings.Split(arg, "|")
+		for _, option := range options {
+			if s == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("the value must be one of %v", strings.Join(options, ", "))
+	case "regexp":
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("the rule regexp cannot be applied to %v", v.Type())
+		}
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return fmt.Errorf("invalid validation rule regexp=%v: %v", arg, err)
+		}
+		if !re.MatchString(v.String()) {
+			return fmt.Errorf("the value must match %q", arg)
+		}
+	default:
+		return fmt.Errorf("unknown validation rule %q", name)
+	}
+	return nil
+}
+
+// ruleOperand returns the number compared by the "min" and "max" rules, which is either the value itself
+// or the length of the value.
+func ruleOperand(v reflect.Value) (float64, bool, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), false, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, nil
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true, nil
+	case reflect.Array, reflect.Slice, reflect.Map:
+		return float64(v.Len()), true, nil
+	}
+	return 0, false, fmt.Errorf("the rules min and max cannot be applied to %v", v.Type())
+}
+
+// ruleLimit parses the argument of the "min" and "max" rules for the value.
+func ruleLimit(v reflect.Value, arg string) (float64, error) {
+	if v.Type() == durationType {
+		if d, err := time.ParseDuration(arg); err == nil {
+			return float64(d), nil
+		}
+	}
+	return strconv.ParseFloat(arg, 64)
+}
@@ -0,0 +1,252 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type validatedServer struct {
+	Host    string        `validate:"nonzero"`
+	Port    int           `validate:"min=1,max=65535"`
+	Mode    string        `validate:"oneof=dev|prod"`
+	Name    string        `validate:"regexp=^[a-z]{0,3}$"`
+	Timeout time.Duration `validate:"max=1m"`
+	Tags    []string      `validate:"max=2"`
+}
+
+func (s *validatedServer) Validate() error {
+	if s.Mode == "prod" && s.Host == "localhost" {
+		return errors.New("localhost cannot be used in production")
+	}
+	return nil
+}
+
+type validatedApp struct {
+	Servers []validatedServer
+	Primary *validatedServer
+	Log     struct {
+		Level string `validate:"oneof=debug|info"`
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		json, path string
+		valid      bool
+	}{
+		{`{"Host": "a", "Port": 80, "Mode": "dev", "Name": "abc", "Timeout": 1000000000, "Tags": ["x"]}`, "", true},
+		{`{"Host": "", "Port": 80, "Mode": "dev"}`, "Host", false},
+		{`{"Host": "a", "Port": 0, "Mode": "dev"}`, "Port", false},
+		{`{"Host": "a", "Port": 65536, "Mode": "dev"}`, "Port", false},
+		{`{"Host": "a", "Port": 80, "Mode": "test"}`, "Mode", false},
+		{`{"Host": "a", "Port": 80, "Mode": "dev", "Name": "abcd"}`, "Name", false},
+		{`{"Host": "a", "Port": 80, "Mode": "dev", "Timeout": 120000000000}`, "Timeout", false},
+		{`{"Host": "a", "Port": 80, "Mode": "dev", "Tags": ["x", "y", "z"]}`, "Tags", false},
+		{`{"Host": "localhost", "Port": 80, "Mode": "prod"}`, "", false},
+	}
+	for _, test := range tests {
+		c := New()
+		c.LoadJSON([]byte(test.json))
+		var s validatedServer
+		err := c.Configure(&s)
+		if test.valid {
+			if err != nil {
+				t.Errorf("Configure(%v): %v", test.json, err)
+			}
+			continue
+		}
+		if e, ok := err.(*ConfigValueError); !ok || e.Path != test.path {
+			t.Errorf("Configure(%v) returned %v, expected a ConfigValueError for %q", test.json, err, test.path)
+		}
+	}
+}
+
+func TestValidateNested(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte("{\n" +
+		`"Servers": [{"Host": "a", "Port": 80, "Mode": "dev"}, {"Host": "b", "Port": 0, "Mode": "dev"}],` + "\n" +
+		`"Log": {"Level": "info"}` + "\n}"))
+	var app validatedApp
+	err := c.Configure(&app)
+	expected := `2:77: "Servers.1.Port" points to an inappropriate configuration value: the value must be at least 1`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Configure() error = %v, expected %v", err, expected)
+	}
+
+	c.Set("Servers.1.Port", 81)
+	if err := c.Configure(&app); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+
+	c.Set("Primary", map[string]interface{}{"Host": "localhost", "Port": 80, "Mode": "prod"})
+	err = c.Configure(&app)
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "Primary" {
+		t.Errorf("Configure() returned %v, expected a ConfigValueError for %q", err, "Primary")
+	}
+
+	c.Set("Primary", nil)
+	c.Set("Log.Level", "trace")
+	if err := c.ConfigureStrict(&app); err == nil {
+		t.Errorf("ConfigureStrict() expected an error for %q, got nil", "Log.Level")
+	}
+	var log struct {
+		Level string `validate:"oneof=debug|info"`
+	}
+	err = c.Configure(&log, "Log")
+	if e, ok := err.(*ConfigValueError); !ok || e.Path != "Log.Level" {
+		t.Errorf("Configure() returned %v, expected a ConfigValueError for %q", err, "Log.Level")
+	}
+}
+
+func TestCheckRule(t *testing.T) {
+	var p *int
+	tests := []struct {
+		value      interface{}
+		name, arg  string
+		shouldFail bool
+	}{
+		{"abc", "min", "3", false},
+		{"abc", "min", "4", true},
+		{"日本語", "max", "3", false},
+		{map[string]int{"a": 1}, "min", "1", false},
+		{uint(5), "max", "4", true},
+		{1.5, "min", "1.5", false},
+		{1, "oneof", "1|2", false},
+		{true, "min", "1", true},
+		{1, "min", "x", true},
+		{1, "regexp", "^1$", true},
+		{"a", "regexp", "(", true},
+		{"a", "required", "", true},
+		{p, "nonzero", "", true},
+		{p, "min", "1", false},
+		{[]int{}, "nonzero", "", false},
+	}
+	for _, test := range tests {
+		v := reflect.ValueOf(test.value)
+		if err := checkRule(v, test.name, test.arg); (err != nil) != test.shouldFail {
+			t.Errorf("checkRule(%#v, %q, %q) = %v", test.value, test.name, test.arg, err)
+		}
+	}
+}
+
+type validatedNode struct {
+	Name string `validate:"nonzero"`
+	Next *validatedNode
+}
+
+func TestValidateCycle(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte(`{"Next": {"Name": "b"}}`))
+	n := &validatedNode{}
+	n.Next = n
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Configure(n)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Configure(): %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Configure() did not finish with a cyclic reference")
+	}
+	if n.Name != "b" || n.Next != n {
+		t.Errorf("Configure() = %+v", n)
+	}
+
+	// the pointers not set by configure() are not validated
+	c.SetData(map[string]interface{}{"Name": "a"})
+	n2 := &validatedNode{Next: &validatedNode{}}
+	if err := c.Configure(n2); err != nil {
+		t.Errorf("Configure() validated a value not being configured: %v", err)
+	}
+}
+
+type CountedValidator struct {
+	Count *int
+}
+
+func (v *CountedValidator) Validate() error {
+	*v.Count++
+	return nil
+}
+
+type validatedOuter struct {
+	CountedValidator
+	Name string
+}
+
+type hiddenValidator struct {
+	CountedValidator
+}
+
+type validatedHidden struct {
+	hiddenValidator
+	Name string
+}
+
+type validatedShadow struct {
+	CountedValidator
+	Name string
+}
+
+func (v *validatedShadow) Validate() error {
+	*v.Count += 10
+	return nil
+}
+
+func TestValidatePromoted(t *testing.T) {
+	c := New()
+	c.LoadJSON([]byte(`{"Name": "a"}`))
+	count := 0
+	outer := validatedOuter{CountedValidator{&count}, ""}
+	if err := c.Configure(&outer); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+	if count != 1 {
+		t.Errorf("the promoted Validate() was called %v times, expected once", count)
+	}
+
+	// the promoted method of an unexported embedded struct is called for the outer struct
+	count = 0
+	hidden := validatedHidden{hiddenValidator{CountedValidator{&count}}, ""}
+	if err := c.Configure(&hidden); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+	if count != 1 {
+		t.Errorf("the promoted Validate() was called %v times, expected once", count)
+	}
+
+	count = 0
+	shadow := validatedShadow{CountedValidator{&count}, ""}
+	if err := c.Configure(&shadow); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+	if count != 10 {
+		t.Errorf("the Validate() methods were counted %v, expected only the outer one", count)
+	}
+
+	// the promoted method is not called through a nil pointer
+	var pointer struct {
+		*CountedValidator
+		Name string
+	}
+	if err := c.Configure(&pointer); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+	count = 0
+	pointer.CountedValidator = &CountedValidator{&count}
+	if err := c.Configure(&pointer); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+	if count != 1 {
+		t.Errorf("the promoted Validate() was called %v times, expected once", count)
+	}
+}
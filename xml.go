@@ -0,0 +1,162 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XMLUnmarshaler returns an UnmarshalFunc that parses XML data into the configuration data.
+//
+// The root element is mapped to the configuration data, which is always a map, and every nested element
+// is mapped to a value keyed by its name in the map of its parent element. Repeated elements with the same
+// name become arrays. A nested element having neither attributes nor child elements is mapped to its text
+// content, which is converted into an integer, a float, or a boolean if it looks like one. Otherwise, the element
+// is mapped to a map, in which the attributes are keyed by their names with attrPrefix, e.g. "@id", and the text
+// content, if not blank, is keyed by textKey, e.g. "#text". Namespace prefixes of the names are ignored.
+//
+// The ".xml" extension is registered in UnmarshalFuncMap with a function equivalent to XMLUnmarshaler("@", "#text").
+// You may register a different one to use a different prefix and text key:
+//
+//	config.UnmarshalFuncMap[".xml"] = config.XMLUnmarshaler("-", "_text")
+//
+// Note that an element appearing only once is not turned into an array. Also note that Position() knows only
+// the file names of the values loaded by a function other than the registered one.
+func XMLUnmarshaler(attrPrefix, textKey string) UnmarshalFunc {
+	return func(data []byte, v interface{}) error {
+		root, _, err := parseXML(data, attrPrefix, textKey)
+		if err != nil {
+			return err
+		}
+		return populate(v, root)
+	}
+}
+
+// unmarshalXML parses the given XML data and populates it into the given variable
+// in the same way as XMLUnmarshaler("@", "#text").
+func unmarshalXML(data []byte, v interface{}) error {
+	return XMLUnmarshaler("@", "#text")(data, v)
+}
+
+// parseDefaultXML parses the given XML data in the same way as unmarshalXML() and returns the parsed value
+// together with the positions of the values, indexed by their paths.
+func parseDefaultXML(data []byte) (interface{}, map[string]Position, error) {
+	return parseXML(data, "@", "#text")
+}
+
+// xmlElement represents an element being parsed.
+type xmlElement struct {
+	values    map[string]interface{} // the attributes and the child elements
+	text      bytes.Buffer           // the text content
+	pos       Position               // the position of the element
+	positions map[string]Position    // the positions of the values, indexed by their paths relative to the element
+}
+
+// parseXML parses the XML data and returns the value of the root element and the positions of the values.
+func parseXML(data []byte, attrPrefix, textKey string) (interface{}, map[string]Position, error) {
+	var root interface{} = map[string]interface{}{}
+	positions := map[string]Position{}
+	hasRoot := false
+	stack := []*xmlElement{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		line, column := decoder.InputPos()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && hasRoot {
+				line, _ := decoder.InputPos()
+				return nil, nil, &xml.SyntaxError{Msg: "multiple root elements", Line: line}
+			}
+			e := &xmlElement{values: map[string]interface{}{}, pos: Position{Line: line, Column: column}, positions: map[string]Position{}}
+			for _, attr := range t.Attr {
+				if attr.Name.Space != "xmlns" && attr.Name.Local != "xmlns" {
+					e.values[attrPrefix+attr.Name.Local] = scalarValue(attr.Value)
+					e.positions[escapeKey(attrPrefix+attr.Name.Local)] = e.pos
+				}
+			}
+			stack = append(stack, e)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				// the root element is always a map, so that loading it never replaces the whole configuration
+				root, hasRoot = e.mapValue(textKey), true
+				positions = e.positions
+				positions[""] = e.pos
+				continue
+			}
+			value := e.value(textKey)
+			parent := stack[len(stack)-1]
+			name := t.Name.Local
+			switch old := parent.values[name].(type) {
+			case nil:
+				parent.values[name] = value
+				parent.addPositions(escapeKey(name), e)
+			case []interface{}:
+				parent.values[name] = append(old, value)
+				parent.addPositions(joinPath(escapeKey(name), strconv.Itoa(len(old))), e)
+			default:
+				parent.values[name] = []interface{}{old, value}
+				parent.movePositions(escapeKey(name), joinPath(escapeKey(name), "0"))
+				parent.addPositions(joinPath(escapeKey(name), "1"), e)
+			}
+		}
+	}
+	return root, positions, nil
+}
+
+// addPositions records the positions of a child element and its values, with the child located at the path.
+func (e *xmlElement) addPositions(path string, child *xmlElement) {
+	e.positions[path] = child.pos
+	for k, pos := range child.positions {
+		e.positions[path+"."+k] = pos
+	}
+}
+
+// movePositions moves the positions of the value at the path "from" and the values under it to the path "to".
+func (e *xmlElement) movePositions(from, to string) {
+	moved := map[string]Position{}
+	for k, pos := range e.positions {
+		if k == from || strings.HasPrefix(k, from+".") {
+			delete(e.positions, k)
+			moved[to+k[len(from):]] = pos
+		}
+	}
+	for k, pos := range moved {
+		e.positions[k] = pos
+	}
+}
+
+// value returns the configuration value that the element is mapped to.
+func (e *xmlElement) value(textKey string) interface{} {
+	if len(e.values) == 0 {
+		return scalarValue(strings.TrimSpace(e.text.String()))
+	}
+	return e.mapValue(textKey)
+}
+
+// mapValue returns the map that the element is mapped to, in which the text content is keyed by textKey if not blank.
+func (e *xmlElement) mapValue(textKey string) map[string]interface{} {
+	if text := strings.TrimSpace(e.text.String()); text != "" {
+		e.values[textKey] = scalarValue(text)
+	}
+	return e.values
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestXMLUnmarshaler(t *testing.T) {
+	tests := []struct {
+		xml      string
+		expected string
+	}{
+		{"", `{}`},
+		{"<config/>", `{}`},
+		{"<config> abc </config>", `{"#text":"abc"}`},
+		{"<config><a>1</a><b>x</b><c/><d>  </d></config>", `{"a":1,"b":"x","c":"","d":""}`},
+		{"<config><a><b><c>true</c></b></a></config>", `{"a":{"b":{"c":true}}}`},
+		{"<config><a>1</a><a>x</a><a><b>2</b></a></config>", `{"a":[1,"x",{"b":2}]}`},
+		{`<config version="2"><server id="web" port="80">primary</server></config>`, `{"@version":2,"server":{"#text":"primary","@id":"web","@port":80}}`},
+		{`<config><server enabled="false"><port>80</port></server></config>`, `{"server":{"@enabled":false,"port":80}}`},
+		{`<config><a><![CDATA[<x> & y]]></a><b>x &amp; y</b></config>`, `{"a":"\u003cx\u003e \u0026 y","b":"x \u0026 y"}`},
+		{`<c:config xmlns:c="urn:c" xmlns="urn:d"><c:a c:x="1">2</c:a></c:config>`, `{"a":{"#text":2,"@x":1}}`},
+		{"<?xml version=\"1.0\"?>\n<!-- comment -->\n<config><!-- comment --><a>1.50</a></config>", `{"a":"1.50"}`},
+	}
+	unmarshal := XMLUnmarshaler("@", "#text")
+	for _, test := range tests {
+		var data interface{}
+		if err := unmarshal([]byte(test.xml), &data); err != nil {
+			t.Errorf("unmarshal(%q): %v", test.xml, err)
+			continue
+		}
+		if s, _ := json.Marshal(data); string(s) != test.expected {
+			t.Errorf("unmarshal(%q) = %v, expected %v", test.xml, string(s), test.expected)
+		}
+	}
+
+	var data interface{}
+	if err := XMLUnmarshaler("-", "_text")([]byte(`<config><a id="1">x</a></config>`), &data); err != nil {
+		t.Errorf("unmarshal(): %v", err)
+	} else if s, _ := json.Marshal(data); string(s) != `{"a":{"-id":1,"_text":"x"}}` {
+		t.Errorf("unmarshal() with a custom prefix = %v", string(s))
+	}
+
+	errors := []struct {
+		xml      string
+		expected string
+	}{
+		{"<config><a></config>", "XML syntax error on line 1: element <a> closed by </config>"},
+		{"<config>\n<a>1</a>", "XML syntax error on line 2: unexpected EOF"},
+		{"<a>1</a>\n<b>2</b>", "XML syntax error on line 2: multiple root elements"},
+	}
+	for _, test := range errors {
+		var data interface{}
+		if err := unmarshal([]byte(test.xml), &data); err == nil || err.Error() != test.expected {
+			t.Errorf("unmarshal(%q) error = %v, expected %v", test.xml, err, test.expected)
+		}
+	}
+}
+
+func TestLoadXML(t *testing.T) {
+	c := New()
+	if err := c.Load("testdata/c1.xml"); err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if v := c.Get("A7.1"); v != "d2" {
+		t.Errorf(`Get("A7.1") = %v, expected %v`, v, "d2")
+	}
+
+	var obj struct {
+		A1 string
+		A2 int
+		A3 bool
+		A4 float64
+		A6 struct {
+			B1 string
+			B2 map[string]string
+		}
+		A7 []string
+	}
+	if err := c.Configure(&obj); err != nil {
+		t.Errorf("Configure(): %v", err)
+	}
+	if obj.A1 != "a1" || obj.A2 != 2 || !obj.A3 || obj.A4 != 2.13 || obj.A6.B2["C1"] != "c1" || len(obj.A7) != 2 {
+		t.Errorf("Configure() = %+v", obj)
+	}
+
+	// an empty root element does not replace the loaded configuration
+	c = New()
+	c.LoadJSON([]byte(`{"A": 1}`))
+	if err := c.Load("testdata/empty.xml"); err != nil {
+		t.Errorf("Load(): %v", err)
+	}
+	if v := c.Get("A"); v != 1.0 {
+		t.Errorf(`Get("A") = %v, expected %v`, v, 1)
+	}
+}
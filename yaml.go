@@ -0,0 +1,255 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlDeleteTag is the YAML tag that marks a key to be removed when merging configurations.
+const yamlDeleteTag = "!delete"
+
+// The number of nodes converted from a YAML document with the aliases expanded is limited to yamlExpansionLimit
+// plus yamlExpansionRatio times the number of nodes in the document, so that a small document with nested aliases
+// (the "billion laughs" attack) cannot take a long time to load.
+const (
+	yamlExpansionLimit = 10000
+	yamlExpansionRatio = 10
+)
+
+// unmarshalYAML parses the given YAML data and populates it into the given variable.
+//
+// When the variable is an empty interface, YAML hashes are populated as map[interface{}]interface{}
+// and values tagged with "!delete" are turned into deletion markers. Anchors and aliases are expanded
+// into separate copies of the anchored values. If the data contain multiple documents separated by "---",
+// the documents are merged in order according to the rules described in SetData(), so the variable is
+// populated with the same value as loading the documents one after another.
+//
+// When the variable is not an empty interface, the documents are decoded into it one after another.
+//
+// The scalars are resolved as they were by yaml.v2 used by earlier versions, so "yes" and "off" are booleans
+// and timestamps are strings. Decoding into a struct follows yaml.v3, which rejects duplicate keys.
+func unmarshalYAML(bytes []byte, data interface{}) error {
+	p, ok := data.(*interface{})
+	if !ok {
+		nodes, err := yamlNodes(bytes)
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			if err := node.Decode(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	docs, _, err := parseYAML(bytes)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		*p = nil
+		return nil
+	}
+	v := reflect.ValueOf(docs[0])
+	for _, doc := range docs[1:] {
+		v = merge(v, reflect.ValueOf(doc), false)
+	}
+	*p = valueInterface(v)
+	return nil
+}
+
+// parseYAML parses the given YAML data and returns the values of the non-empty documents, which are merged
+// in order by Load() as if they were loaded from separate files, together with the positions of the values
+// in each document. The values are populated in the same way as unmarshalYAML() populates an empty interface.
+func parseYAML(bytes []byte) ([]interface{}, []map[string]Position, error) {
+	nodes, err := yamlNodes(bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	docs := []interface{}{}
+	positions := []map[string]Position{}
+	for _, node := range nodes {
+		m := map[string]Position{}
+		budget := yamlExpansionLimit + yamlExpansionRatio*yamlCount(node)
+		v, err := yamlValue(node, "", m, &budget)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v != nil {
+			docs = append(docs, v)
+			positions = append(positions, m)
+		}
+	}
+	return docs, positions, nil
+}
+
+// yamlNodes parses the documents in the given YAML data and returns the non-empty ones.
+func yamlNodes(bytes []byte) ([]*yaml.Node, error) {
+	var nodes []*yaml.Node
+	decoder := yaml.NewDecoder(strings.NewReader(string(bytes)))
+	for {
+		node := &yaml.Node{}
+		if err := decoder.Decode(node); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(node.Content) > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// yamlCount returns the number of nodes in a YAML document without expanding the aliases.
+func yamlCount(node *yaml.Node) int {
+	n := 1
+	for _, child := range node.Content {
+		n += yamlCount(child)
+	}
+	return n
+}
+
+// yamlValue converts a YAML node located at the path into the corresponding configuration value.
+// The positions of the node and its descendants are recorded in the positions map. The budget is
+// the number of nodes that may still be converted, and an error is returned if it is used up.
+func yamlValue(node *yaml.Node, path string, positions map[string]Position, budget *int) (interface{}, error) {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(node.Content[0], path, positions, budget)
+	}
+	if *budget--; *budget < 0 {
+		return nil, &ParseError{yamlPosition(node), "document contains excessive aliasing"}
+	}
+	positions[path] = yamlPosition(node)
+	if node.Tag == yamlDeleteTag {
+		return map[interface{}]interface{}{deleteKey: true}, nil
+	}
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		v, err := yamlValue(node.Alias, path, positions, budget)
+		positions[path] = yamlPosition(node)
+		return v, err
+	case yaml.SequenceNode:
+		s := make([]interface{}, len(node.Content))
+		for i, n := range node.Content {
+			v, err := yamlValue(n, joinPath(path, strconv.Itoa(i)), positions, budget)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = v
+		}
+		return s, nil
+	case yaml.MappingNode:
+		return yamlMap(node, path, positions, budget)
+	case yaml.ScalarNode:
+		return yamlScalar(node)
+	}
+	return nil, nil
+}
+
+// yamlBools maps the plain scalars that are booleans in YAML 1.1 to their values.
+var yamlBools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true, "on": true, "On": true, "ON": true,
+	"n": false, "N": false, "no": false, "No": false, "NO": false, "off": false, "Off": false, "OFF": false,
+}
+
+// yamlScalar converts a YAML scalar node into the corresponding configuration value. The scalars are resolved
+// in the same way as yaml.v2 used by earlier versions: the plain scalars such as "yes" and "off" are booleans,
+// and the timestamps without the "!!timestamp" tag are kept as strings. Map keys are not resolved by this function.
+func yamlScalar(node *yaml.Node) (interface{}, error) {
+	if node.Style == 0 {
+		switch node.ShortTag() {
+		case "!!str":
+			if b, ok := yamlBools[node.Value]; ok {
+				return b, nil
+			}
+		case "!!timestamp":
+			return node.Value, nil
+		}
+	}
+	var v interface{}
+	err := node.Decode(&v)
+	return v, err
+}
+
+// yamlMap converts a YAML mapping node located at the path into a map. Keys brought in via the "<<" merge key
+// are overridden by the keys explicitly specified in the mapping.
+func yamlMap(node *yaml.Node, path string, positions map[string]Position, budget *int) (interface{}, error) {
+	m := make(map[interface{}]interface{})
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Tag != "!!merge" {
+			continue
+		}
+		if err := yamlMerge(m, node.Content[i+1], path, positions, budget); err != nil {
+			return nil, err
+		}
+	}
+	positions[path] = yamlPosition(node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Tag == "!!merge" {
+			continue
+		}
+		var k interface{} = node.Content[i].Value
+		if node.Content[i].Kind != yaml.ScalarNode || node.Content[i].ShortTag() != "!!str" {
+			// the keys such as "on" and "y" are kept as strings rather than booleans
+			var err error
+			if k, err = yamlValue(node.Content[i], "", map[string]Position{}, budget); err != nil {
+				return nil, err
+			}
+		}
+		switch k.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return nil, &ParseError{yamlPosition(node.Content[i]), fmt.Sprintf("invalid map key: %v", k)}
+		}
+		v, err := yamlValue(node.Content[i+1], joinPath(path, fmt.Sprint(k)), positions, budget)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// yamlMerge adds the keys of the mapping(s) referenced by a "<<" merge key to the given map located at the path.
+// When a sequence of mappings is merged, the earlier mappings take precedence.
+func yamlMerge(m map[interface{}]interface{}, node *yaml.Node, path string, positions map[string]Position, budget *int) error {
+	if node.Kind == yaml.SequenceNode {
+		for i := len(node.Content) - 1; i >= 0; i-- {
+			if err := yamlMerge(m, node.Content[i], path, positions, budget); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	v, err := yamlValue(node, path, positions, budget)
+	if err != nil {
+		return err
+	}
+	mv, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return &ParseError{yamlPosition(node), "map merge requires map or sequence of maps as the value"}
+	}
+	for k, e := range mv {
+		m[k] = e
+	}
+	return nil
+}
+
+// yamlPosition returns the position of a YAML node.
+func yamlPosition(node *yaml.Node) Position {
+	return Position{Line: node.Line, Column: node.Column}
+}
@@ -0,0 +1,236 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		yaml     string
+		path     string
+		expected interface{}
+	}{
+		{"a: 1", "a", 1},
+		{"a: 1.5", "a", 1.5},
+		{"a: abc", "a", "abc"},
+		{"a: true", "a", true},
+		{"a: [1, abc]", "a.1", "abc"},
+		{"a:\n  b:\n    c: 1", "a.b.c", 1},
+		{"b: &x {c: 1, d: 2}\na:\n  <<: *x\n  d: 3", "a.c", 1},
+		{"b: &x {c: 1, d: 2}\na:\n  <<: *x\n  d: 3", "a.d", 3},
+		{"b: &x {c: 1}\ne: &y {c: 2, d: 2}\na:\n  <<: [*x, *y]", "a.c", 1},
+		{"b: &x {c: 1}\ne: &y {c: 2, d: 2}\na:\n  <<: [*x, *y]", "a.d", 2},
+		{"b: &x abc\na: *x", "a", "abc"},
+	}
+	for _, test := range tests {
+		var data interface{}
+		if err := unmarshalYAML([]byte(test.yaml), &data); err != nil {
+			t.Errorf("unmarshalYAML(%q): %v", test.yaml, err)
+			continue
+		}
+		c := New()
+		c.SetData(data)
+		if v := c.Get(test.path); v != test.expected {
+			t.Errorf("unmarshalYAML(%q), Get(%q) = %v, expected %v", test.yaml, test.path, v, test.expected)
+		}
+	}
+
+	for _, s := range []string{"a: !delete", "a: !delete ~", "a: !delete {b: 1}"} {
+		var data interface{}
+		if err := unmarshalYAML([]byte(s), &data); err != nil {
+			t.Errorf("unmarshalYAML(%q): %v", s, err)
+			continue
+		}
+		if v := data.(map[interface{}]interface{})["a"]; !isDeleteMarker(reflect.ValueOf(v)) {
+			t.Errorf("unmarshalYAML(%q): got %v, expected a deletion marker", s, v)
+		}
+	}
+
+	var data interface{}
+	if err := unmarshalYAML([]byte(""), &data); err != nil || data != nil {
+		t.Errorf(`unmarshalYAML(""): got %v, %v, expected nil, nil`, data, err)
+	}
+	if err := unmarshalYAML([]byte("a: [1"), &data); err == nil {
+		t.Errorf(`unmarshalYAML("a: [1") expected an error, got nil`)
+	}
+	if err := unmarshalYAML([]byte("a:\n  <<: 1"), &data); err == nil {
+		t.Errorf(`unmarshalYAML("a:\n  <<: 1") expected an error, got nil`)
+	}
+
+	var obj struct {
+		A int
+		B []string
+	}
+	if err := unmarshalYAML([]byte("a: 1\nb: [x, y]"), &obj); err != nil {
+		t.Errorf("unmarshalYAML(struct): %v", err)
+	}
+	if s, _ := json.Marshal(obj); string(s) != `{"A":1,"B":["x","y"]}` {
+		t.Errorf("unmarshalYAML(struct) = %v, expected %v", string(s), `{"A":1,"B":["x","y"]}`)
+	}
+}
+
+func TestUnmarshalYAMLScalars(t *testing.T) {
+	// the scalars are resolved in the same way as yaml.v2
+	var data interface{}
+	if err := unmarshalYAML([]byte("a: yes\nb: off\nc: 'yes'\nd: 2001-12-14\ne: !!timestamp 2001-12-14\nf: 0x1F\ng: 9223372036854775808\non: y"), &data); err != nil {
+		t.Fatalf("unmarshalYAML(): %v", err)
+	}
+	expected := map[interface{}]interface{}{
+		"a":  true,
+		"b":  false,
+		"c":  "yes",
+		"d":  "2001-12-14",
+		"e":  time.Date(2001, 12, 14, 0, 0, 0, 0, time.UTC),
+		"f":  31,
+		"g":  uint64(9223372036854775808),
+		"on": true,
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("unmarshalYAML() = %#v, expected %#v", data, expected)
+	}
+
+	c := New()
+	c.LoadJSON([]byte(`{}`))
+	if err := c.Load("testdata/c6.yaml"); err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	var app struct {
+		Release string
+		Debug   bool
+	}
+	if err := c.Configure(&app); err != nil || app.Release != "2001-12-14" || !app.Debug {
+		t.Errorf("Configure() = %+v, %v", app, err)
+	}
+	if !c.GetBool("Debug") || c.GetString("Release") != "2001-12-14" {
+		t.Errorf("GetBool() = %v, GetString() = %q", c.GetBool("Debug"), c.GetString("Release"))
+	}
+
+	var obj struct{ A int }
+	if err := unmarshalYAML([]byte("a: 1\na: 2"), &obj); err == nil {
+		t.Errorf("unmarshalYAML(struct) with duplicate keys: expected an error, got nil")
+	}
+}
+
+func TestUnmarshalYAMLDocuments(t *testing.T) {
+	var data interface{}
+	if err := unmarshalYAML([]byte("a: 1\n---\nb: 2\n---\n---\nc: 3\n"), &data); err != nil {
+		t.Fatalf("unmarshalYAML(): %v", err)
+	}
+	if s, _ := json.Marshal(data); string(s) != `{"a":1,"b":2,"c":3}` {
+		t.Errorf("unmarshalYAML() = %v, expected the documents to be merged", string(s))
+	}
+	if _, ok := data.(map[interface{}]interface{}); !ok {
+		t.Errorf("unmarshalYAML() = %T, expected a map", data)
+	}
+	if err := unmarshalYAML([]byte("a: 1\nb: {x: 1, y: 2}\n---\na: !delete\nb: {y: 3}\n"), &data); err != nil {
+		t.Errorf("unmarshalYAML(): %v", err)
+	} else if s, _ := json.Marshal(data); string(s) != `{"b":{"x":1,"y":3}}` {
+		t.Errorf("unmarshalYAML() = %v, expected %v", string(s), `{"b":{"x":1,"y":3}}`)
+	}
+	docs, positions, err := parseYAML([]byte("a: 1\n---\nb: 2\n---\n---\nc: 3\n"))
+	if err != nil || len(docs) != 3 || len(positions) != 3 {
+		t.Errorf("parseYAML() = %v, %v, %v, expected three documents", docs, positions, err)
+	} else if pos := positions[2]["c"]; pos != (Position{Line: 6, Column: 4}) {
+		t.Errorf("parseYAML() position of %q = %v, expected %v", "c", pos, "6:4")
+	}
+	if err := unmarshalYAML([]byte("---\na: 1\n"), &data); err != nil || reflect.TypeOf(data) != reflect.TypeOf(map[interface{}]interface{}{}) {
+		t.Errorf("unmarshalYAML() with a single document = %T, %v", data, err)
+	}
+	if err := unmarshalYAML([]byte("---\n"), &data); err != nil || data != nil {
+		t.Errorf("unmarshalYAML() with an empty document = %v, %v", data, err)
+	}
+	if err := unmarshalYAML([]byte("a: 1\n---\nb: [1"), &data); err == nil {
+		t.Errorf("unmarshalYAML() with a malformed document: expected an error, got nil")
+	}
+
+	var obj struct {
+		A int
+		B int
+	}
+	if err := unmarshalYAML([]byte("a: 1\nb: 1\n---\nb: 2"), &obj); err != nil || obj.A != 1 || obj.B != 2 {
+		t.Errorf("unmarshalYAML(struct) = %+v, %v", obj, err)
+	}
+}
+
+func TestLoadYAMLDocuments(t *testing.T) {
+	c := New()
+	if err := c.Load("testdata/c1.json", "testdata/c5.yaml"); err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"A1", nil},
+		{"A2", 2.0},
+		{"Cache", nil},
+		{"DB.Host", "localhost"},
+		{"DB.Port", 3307},
+		{"DB.Options.Timeout", 10},
+		{"defaults.Host", "localhost"},
+	}
+	for _, test := range tests {
+		if v := c.Get(test.path); v != test.expected {
+			t.Errorf("Get(%q) = %v, expected %v", test.path, v, test.expected)
+		}
+	}
+	if s := c.Source("DB.Port"); s != "testdata/c5.yaml" {
+		t.Errorf(`Source("DB.Port") = %q, expected %q`, s, "testdata/c5.yaml")
+	}
+
+	// anchored values are copied, so changing a copy does not affect the others
+	c.Set("DB.Host", "db.example.com")
+	c.Set("DB.Options.Timeout", 20)
+	if v := c.Get("defaults.Host"); v != "localhost" {
+		t.Errorf(`Get("defaults.Host") = %v, expected %v`, v, "localhost")
+	}
+	if v := c.Get("defaults.Options.Timeout"); v != 10 {
+		t.Errorf(`Get("defaults.Options.Timeout") = %v, expected %v`, v, 10)
+	}
+
+	c = New()
+	c.Load("testdata/c5.yaml")
+	c.Set("DB.Options.Timeout", 20)
+	c.Set("defaults.Options.Timeout", 30)
+	if v := c.Get("DB.Options.Timeout"); v != 20 {
+		t.Errorf(`Get("DB.Options.Timeout") = %v, expected %v`, v, 20)
+	}
+
+	var data interface{}
+	unmarshalYAML([]byte("a: &x {b: {c: 1}}\nd: *x\ne: [*x, *x]"), &data)
+	m := data.(map[interface{}]interface{})
+	m["d"].(map[interface{}]interface{})["b"].(map[interface{}]interface{})["c"] = 2
+	m["e"].([]interface{})[0].(map[interface{}]interface{})["b"].(map[interface{}]interface{})["c"] = 3
+	if s, _ := json.Marshal(data); string(s) != `{"a":{"b":{"c":1}},"d":{"b":{"c":2}},"e":[{"b":{"c":3}},{"b":{"c":1}}]}` {
+		t.Errorf("aliases are not copied: %v", string(s))
+	}
+}
+
+func TestUnmarshalYAMLAliasing(t *testing.T) {
+	// every level refers to the previous one ten times, so the last one expands to a billion strings
+	laughs := "a: &a [lol, lol, lol, lol, lol, lol, lol, lol, lol, lol]\n"
+	for i := 'b'; i <= 'i'; i++ {
+		laughs += fmt.Sprintf("%c: &%c [*%c, *%c, *%c, *%c, *%c, *%c, *%c, *%c, *%c, *%c]\n", i, i, i-1, i-1, i-1, i-1, i-1, i-1, i-1, i-1, i-1, i-1)
+	}
+	var data interface{}
+	err := unmarshalYAML([]byte(laughs), &data)
+	if e, ok := err.(*ParseError); !ok || e.Message != "document contains excessive aliasing" {
+		t.Errorf("unmarshalYAML() = %v, expected a ParseError for excessive aliasing", err)
+	}
+
+	// a moderate number of aliases is allowed
+	if err := unmarshalYAML([]byte(laughs[:strings.Index(laughs, "\nd:")]), &data); err != nil {
+		t.Errorf("unmarshalYAML(): %v", err)
+	} else if s, _ := json.Marshal(data.(map[interface{}]interface{})["c"]); strings.Count(string(s), "lol") != 1000 {
+		t.Errorf("unmarshalYAML() = %s, expected 1000 strings", s)
+	}
+}